@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Subscriptions bought in advance: created disabled and enabled by the scheduler
+type PendingActivation struct {
+	UUID       string    `json:"uuid"`
+	Username   string    `json:"username"`
+	StartAt    time.Time `json:"startAt"`
+	Days       int       `json:"days"`
+	TelegramID int64     `json:"telegramId"`
+}
+
+// startDateFor resolves a preset start choice of the create flow; zero time means "now"
+func startDateFor(choice string, now time.Time) time.Time {
+	switch choice {
+	case "tomorrow":
+		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	case "month":
+		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Time{}
+}
+
+func parseStartDate(text string, now time.Time) (time.Time, error) {
+	date, err := time.ParseInLocation("02.01.2006", strings.TrimSpace(text), now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверный формат даты")
+	}
+	if !date.After(now) {
+		return time.Time{}, fmt.Errorf("дата должна быть в будущем")
+	}
+	if date.After(now.AddDate(1, 0, 0)) {
+		return time.Time{}, fmt.Errorf("дата не может быть позже чем через год")
+	}
+	return date, nil
+}
+
+func schedulePendingActivation(user *RemnawaveUser, params ClientParams, telegramID int64) error {
+	return store.update(func(d *storeData) {
+		d.PendingActivations[user.UUID] = &PendingActivation{
+			UUID:       user.UUID,
+			Username:   user.Username,
+			StartAt:    params.StartAt,
+			Days:       params.Days,
+			TelegramID: telegramID,
+		}
+	})
+}
+
+// runScheduledActivations enables clients whose start time has come, counting expiry from now
+func runScheduledActivations(ctx context.Context, bot *tgbotapi.BotAPI, now time.Time) {
+	var due []PendingActivation
+	store.view(func(d *storeData) {
+		for _, a := range d.PendingActivations {
+			if !a.StartAt.After(now) {
+				due = append(due, *a)
+			}
+		}
+	})
+
+	for _, a := range due {
+		// Someone is editing the client right now, retry on the next tick
+		if clientLocked(a.UUID) {
+			continue
+		}
+
+		expireAt := now.AddDate(0, 0, a.Days)
+		if _, err := updateRemnawaveUser(ctx, UpdateUserRequest{UUID: a.UUID, ExpireAt: expireAt.UTC().Format(time.RFC3339)}); err != nil {
+			log.Printf("Failed to activate %s: %v", a.Username, err)
+			continue
+		}
+		if err := setUserEnabled(ctx, a.UUID, true); err != nil {
+			log.Printf("Failed to activate %s: %v", a.Username, err)
+			continue
+		}
+
+		if err := store.update(func(d *storeData) { delete(d.PendingActivations, a.UUID) }); err != nil {
+			log.Printf("Failed to save activation of %s: %v", a.Username, err)
+		}
+		log.Printf("Activated scheduled client %s", a.Username)
+
+		if a.TelegramID != 0 {
+			notifyUser(ctx, bot, a.TelegramID, notifySubscription, fmt.Sprintf(
+				"✅ Подписка `%s` активирована и действует до *%s*.",
+				a.Username,
+				expireAt.Format("02.01.2006"),
+			))
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Audit log of destructive and administrative actions, newest last
+const maxAuditEntries = 1000
+
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	ActorID int64     `json:"actorId"`
+	Action  string    `json:"action"`
+	Target  string    `json:"target"`
+	Details string    `json:"details,omitempty"`
+}
+
+func recordAudit(actorID int64, action, target, details string) {
+	entry := &AuditEntry{
+		Time:    time.Now(),
+		ActorID: actorID,
+		Action:  action,
+		Target:  target,
+		Details: details,
+	}
+	err := store.update(func(d *storeData) {
+		d.AuditLog = append(d.AuditLog, entry)
+		if len(d.AuditLog) > maxAuditEntries {
+			d.AuditLog = d.AuditLog[len(d.AuditLog)-maxAuditEntries:]
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to record audit entry %s %s: %v", action, target, err)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Creating several clients with identical settings in one wizard run
+const maxBatchNames = 20
+
+type BatchResult struct {
+	Name    string
+	Link    string
+	Expires time.Time
+	Err     error
+}
+
+// The last batch of each admin stays available for the CSV download for a while
+const batchResultsTTL = time.Hour
+
+type BatchRun struct {
+	Results   []BatchResult
+	CreatedAt time.Time
+}
+
+var (
+	batchResults   = make(map[int64]BatchRun)
+	batchResultsMu sync.Mutex
+)
+
+// Telegram's limit is 4096 characters, the rest of the list is left to the CSV
+const (
+	maxBatchMessageLen = 3500
+	maxBatchErrorLen   = 100
+)
+
+// parseClientNames splits a comma- or newline-separated list, returning the invalid entries separately
+func parseClientNames(text string) (names, invalid []string) {
+	seen := make(map[string]bool)
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		name := strings.TrimSpace(part)
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if validClientName(name) {
+			names = append(names, name)
+		} else {
+			invalid = append(invalid, name)
+		}
+	}
+	return names, invalid
+}
+
+func finishBatchCreation(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, names []string, params ClientParams) {
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⏳ Создаю клиентов: %d...", len(names))))
+
+	results := make([]BatchResult, 0, len(names))
+	created := 0
+	for _, name := range names {
+		p := params
+		p.Name = name
+
+		user, err := createClient(ctx, p, userID)
+		if err != nil {
+			results = append(results, BatchResult{Name: name, Err: err})
+			continue
+		}
+		created++
+		results = append(results, BatchResult{
+			Name:    user.Username,
+			Link:    subscriptionLink(user.ShortUUID),
+			Expires: p.expireAt(),
+		})
+	}
+
+	batchResultsMu.Lock()
+	for id, run := range batchResults {
+		if time.Since(run.CreatedAt) > batchResultsTTL {
+			delete(batchResults, id)
+		}
+	}
+	batchResults[userID] = BatchRun{Results: results, CreatedAt: time.Now()}
+	batchResultsMu.Unlock()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "✅ *Создано клиентов: %d из %d*\n\n", created, len(names))
+	fmt.Fprintf(&sb, "📊 Трафик: *%s*\n⏳ Срок: *%d дней*\n📅 Истекает: *%s*\n\n",
+		trafficText(params.TrafficGB), params.Days, params.expireAt().Format("02.01.2006"))
+	for i, r := range results {
+		line := fmt.Sprintf("👤 `%s`\n`%s`\n", r.Name, r.Link)
+		if r.Err != nil {
+			line = fmt.Sprintf("❌ `%s` — `%s`\n", r.Name, shortError(r.Err))
+		}
+		if sb.Len()+len(line) > maxBatchMessageLen {
+			fmt.Fprintf(&sb, "...и ещё %d, полный список в CSV\n", len(results)-i)
+			break
+		}
+		sb.WriteString(line)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Скачать CSV", "batch_csv"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Главное меню", "main_menu"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	if err := sendLinkMessage(bot, msg, "", ""); err != nil {
+		log.Printf("Failed to send batch results: %v", err)
+		fallback := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Создано клиентов: %d из %d. Не удалось показать список, скачайте CSV.", created, len(names)))
+		fallback.ReplyMarkup = keyboard
+		bot.Send(fallback)
+	}
+}
+
+// shortError cuts a panel error down for a Markdown code span
+func shortError(err error) string {
+	text := strings.ReplaceAll(err.Error(), "`", "'")
+	if runes := []rune(text); len(runes) > maxBatchErrorLen {
+		text = string(runes[:maxBatchErrorLen]) + "…"
+	}
+	return text
+}
+
+func handleBatchCSV(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	batchResultsMu.Lock()
+	run := batchResults[userID]
+	batchResultsMu.Unlock()
+
+	var results []BatchResult
+	if time.Since(run.CreatedAt) <= batchResultsTTL {
+		results = run.Results
+	}
+
+	if len(results) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Нет результатов для выгрузки."))
+		return
+	}
+	if !linksAllowed(chatID) {
+		bot.Send(tgbotapi.NewMessage(chatID, "🔒 CSV со ссылками не отправляется в группу, выгрузите его в личном чате с ботом."))
+		return
+	}
+
+	rows := [][]string{{"username", "subscription_url", "expire_at", "error"}}
+	for _, r := range results {
+		if r.Err != nil {
+			rows = append(rows, []string{r.Name, "", "", r.Err.Error()})
+			continue
+		}
+		rows = append(rows, []string{r.Name, r.Link, r.Expires.Format("2006-01-02"), ""})
+	}
+
+	data, err := renderReportCSV(rows)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось сформировать CSV."))
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("clients_%s.csv", time.Now().Format("2006-01-02_1504")),
+		Bytes: data,
+	})
+	if err := sendLinkDocument(bot, doc); err != nil {
+		log.Printf("Failed to send batch CSV: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось отправить CSV."))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runCLI handles offline maintenance commands: "botik genkey" and "botik decrypt"
+func runCLI(args []string) error {
+	switch args[0] {
+	case "genkey":
+		key, err := generateStoreKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+
+	case "decrypt":
+		return runDecrypt(args[1:])
+
+	default:
+		return fmt.Errorf("unknown command %q, expected genkey or decrypt", args[0])
+	}
+}
+
+// runDecrypt prints the data file as plain JSON, e.g. for backups or moving off encryption
+func runDecrypt(args []string) error {
+	flags := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	configPath := flags.String("config", configFile, "config file with the encryption section")
+	keyFile := flags.String("key", "", "key file, overrides encryption.keyFile from the config")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	path := dataFile
+	if flags.NArg() > 0 {
+		path = flags.Arg(0)
+	}
+
+	c, err := loadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	enc := c.Encryption
+	if *keyFile != "" {
+		enc = &EncryptionConfig{KeyFile: *keyFile}
+	}
+	keys, err := loadStoreKeys(enc)
+	if err != nil {
+		return fmt.Errorf("failed to load encryption keys: %w", err)
+	}
+
+	plain, _, err := readStoreFile(path, keys)
+	if err != nil {
+		return err
+	}
+	if len(plain) == 0 {
+		return fmt.Errorf("%s is empty or missing", path)
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, plain, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err = os.Stdout.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// cloneParams copies an existing user's settings; the duration is the originally sold one when known
+func cloneParams(user *RemnawaveUser) ClientParams {
+	params := ClientParams{
+		TrafficGB: int(user.TrafficLimitBytes / (1024 * 1024 * 1024)),
+		Inbounds:  []InboundTag{},
+	}
+
+	store.view(func(d *storeData) {
+		if c, ok := d.Clients[user.UUID]; ok {
+			params.Days = c.Days
+		}
+	})
+	if params.Days == 0 {
+		params.Days = max(int(math.Ceil(time.Until(user.ExpireAt).Hours()/24)), 1)
+	}
+
+	for _, sq := range user.ActiveInternalSquads {
+		params.SquadUUIDs = append(params.SquadUUIDs, sq.UUID)
+	}
+	for _, inb := range user.ActiveUserInbounds {
+		params.Inbounds = append(params.Inbounds, InboundTag{Tag: inb.Tag})
+	}
+	if user.HwidDeviceLimit != nil {
+		params.Devices = *user.HwidDeviceLimit
+	}
+	if user.Tag != nil {
+		params.Tag = *user.Tag
+	}
+	return params
+}
+
+func handleClone(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, uuid string) {
+	user, err := getUserByUUID(ctx, uuid)
+	if err != nil {
+		log.Printf("Failed to get user %s for cloning: %v", uuid, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Клиент не найден."))
+		return
+	}
+
+	params := cloneParams(user)
+
+	squads := "—"
+	if len(user.ActiveInternalSquads) > 0 {
+		var names []string
+		for _, sq := range user.ActiveInternalSquads {
+			names = append(names, sq.Name)
+		}
+		squads = strings.Join(names, ", ")
+	}
+	devices := "без ограничений"
+	if params.Devices > 0 {
+		devices = fmt.Sprintf("%d", params.Devices)
+	}
+
+	text := fmt.Sprintf(
+		"📑 *Копия настроек* `%s`\n\n"+
+			"📊 Трафик: *%s*\n"+
+			"⏳ Срок: *%d дней*\n"+
+			"👥 Сквады: `%s`\n"+
+			"🔌 Инбаунды: *%d*\n"+
+			"📱 Устройства: *%s*\n",
+		user.Username,
+		trafficText(params.TrafficGB),
+		params.Days,
+		squads,
+		len(params.Inbounds),
+		devices,
+	)
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+
+	startFlow(ctx, bot, chatID, userID, "clone_client", func(s *WizardSession) { s.Params = params })
+}
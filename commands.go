@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Argument-based admin commands, a faster alternative to the button wizard
+type CommandArgs struct {
+	Positional []string
+	Flags      map[string]string
+}
+
+type textCommand struct {
+	Usage       string
+	Description string
+	Args        int      // required positional arguments
+	Flags       []string // allowed --flags, all of them take a value
+	Run         func(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message, args *CommandArgs) error
+}
+
+var textCommands = map[string]*textCommand{
+	"create": {
+		Usage:       "/create <имя> <трафик_GB> <дней> [--squad ИМЯ] [--devices N] [--tg ID] [--start ДД.ММ.ГГГГ]",
+		Description: "создать клиента, трафик 0 — безлимит",
+		Args:        3,
+		Flags:       []string{"squad", "devices", "tg", "start"},
+		Run:         runCreateCommand,
+	},
+	"extend": {
+		Usage:       "/extend <имя> <дней>",
+		Description: "продлить подписку",
+		Args:        2,
+		Run:         runExtendCommand,
+	},
+	"disable": {
+		Usage:       "/disable <имя>",
+		Description: "отключить клиента",
+		Args:        1,
+		Run:         runDisableCommand,
+	},
+	"enable": {
+		Usage:       "/enable <имя>",
+		Description: "включить клиента",
+		Args:        1,
+		Run:         runEnableCommand,
+	},
+	"freeze": {
+		Usage:       "/freeze <имя>",
+		Description: "заморозить подписку с сохранением остатка срока",
+		Args:        1,
+		Run:         runFreezeCommand,
+	},
+	"resume": {
+		Usage:       "/resume <имя>",
+		Description: "возобновить замороженную подписку",
+		Args:        1,
+		Run:         runResumeCommand,
+	},
+	"family": {
+		Usage:       "/family <tg_id> <участников> <трафик_GB> <дней>",
+		Description: "выдать семейный план, трафик — общая квота, 0 — безлимит",
+		Args:        4,
+		Run:         runFamilyCommand,
+	},
+}
+
+// parseCommandArgs splits arguments on spaces, honouring quotes and --flag value / --flag=value
+func parseCommandArgs(s string) (*CommandArgs, error) {
+	var tokens []string
+	var cur strings.Builder
+	var quote rune
+	inToken := false
+
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inToken = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inToken {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+		default:
+			cur.WriteRune(r)
+			inToken = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("незакрытая кавычка")
+	}
+	if inToken {
+		tokens = append(tokens, cur.String())
+	}
+
+	args := &CommandArgs{Flags: make(map[string]string)}
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if !strings.HasPrefix(tok, "--") {
+			args.Positional = append(args.Positional, tok)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(tok, "--"), "=")
+		if name == "" {
+			return nil, fmt.Errorf("пустое имя флага")
+		}
+		if !hasValue {
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("флаг --%s требует значение", name)
+			}
+			i++
+			value = tokens[i]
+		}
+		args.Flags[name] = value
+	}
+	return args, nil
+}
+
+func (a *CommandArgs) intFlag(name string, min int) (int, error) {
+	v, ok := a.Flags[name]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min {
+		return 0, fmt.Errorf("--%s: ожидается число не меньше %d", name, min)
+	}
+	return n, nil
+}
+
+func parseDays(s string) (int, error) {
+	days, err := strconv.Atoi(s)
+	if err != nil || days <= 0 || days > 3650 {
+		return 0, fmt.Errorf("срок должен быть числом дней от 1 до 3650")
+	}
+	return days, nil
+}
+
+// maxTrafficGB keeps the limit in bytes far from overflowing int64
+const maxTrafficGB = 100000
+
+func parseTrafficGB(s string) (int, error) {
+	gb, err := strconv.Atoi(s)
+	if err != nil || gb < 0 || gb > maxTrafficGB {
+		return 0, fmt.Errorf("трафик должен быть числом GB от 0 до %d, 0 — безлимит", maxTrafficGB)
+	}
+	return gb, nil
+}
+
+func handleTextCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message, cmd *textCommand) {
+	chatID := msg.Chat.ID
+
+	args, err := parseCommandArgs(msg.CommandArguments())
+	if err == nil {
+		err = validateCommandArgs(cmd, args)
+	}
+	if err != nil {
+		m := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ %s\n\nИспользование:\n`%s`", err.Error(), cmd.Usage))
+		m.ParseMode = "Markdown"
+		bot.Send(m)
+		return
+	}
+
+	if err := cmd.Run(ctx, bot, msg, args); err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+	}
+}
+
+func validateCommandArgs(cmd *textCommand, args *CommandArgs) error {
+	if len(args.Positional) != cmd.Args {
+		return fmt.Errorf("ожидается аргументов: %d", cmd.Args)
+	}
+	for name := range args.Flags {
+		allowed := false
+		for _, f := range cmd.Flags {
+			if f == name {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("неизвестный флаг --%s", name)
+		}
+	}
+	return nil
+}
+
+func sendCommandHelp(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64) {
+	names := make([]string, 0, len(textCommands))
+	for name := range textCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("📖 *Команды*\n\n")
+	for _, name := range names {
+		cmd := textCommands[name]
+		fmt.Fprintf(&sb, "`%s`\n%s\n\n", cmd.Usage, cmd.Description)
+	}
+	sb.WriteString("Пример: `/create ivan 100 30 --squad EU --devices 3`")
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+func runCreateCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message, args *CommandArgs) error {
+	params := ClientParams{Name: args.Positional[0], Squad: args.Flags["squad"]}
+
+	if !validClientName(params.Name) {
+		return fmt.Errorf("имя должно содержать только латиницу, цифры, дефис или подчёркивание")
+	}
+
+	var err error
+	if params.TrafficGB, err = parseTrafficGB(args.Positional[1]); err != nil {
+		return err
+	}
+	if params.Days, err = parseDays(args.Positional[2]); err != nil {
+		return err
+	}
+	if params.Devices, err = args.intFlag("devices", 0); err != nil {
+		return err
+	}
+	if tg, ok := args.Flags["tg"]; ok {
+		if params.TelegramID, err = strconv.ParseInt(tg, 10, 64); err != nil || params.TelegramID <= 0 {
+			return fmt.Errorf("--tg: ожидается Telegram ID")
+		}
+	}
+	if start, ok := args.Flags["start"]; ok {
+		if params.StartAt, err = parseStartDate(start, time.Now()); err != nil {
+			return fmt.Errorf("--start: %w", err)
+		}
+	}
+
+	finishClientCreation(ctx, bot, msg.Chat.ID, msg.From.ID, params)
+	return nil
+}
+
+func runExtendCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message, args *CommandArgs) error {
+	days, err := parseDays(args.Positional[1])
+	if err != nil {
+		return err
+	}
+
+	user, err := getUserByUsername(ctx, args.Positional[0])
+	if err != nil {
+		return err
+	}
+
+	// Extend from today if the subscription has already expired
+	base := user.ExpireAt
+	if base.Before(time.Now()) {
+		base = time.Now()
+	}
+	expireAt := base.AddDate(0, 0, days)
+
+	text := fmt.Sprintf("⏳ Продлить `%s` на %d дней?\n📅 Сейчас истекает: *%s*, станет: *%s*",
+		user.Username, days, user.ExpireAt.Format("02.01.2006"), expireAt.Format("02.01.2006"))
+	return confirmEdit(ctx, bot, msg.Chat.ID, msg.From.ID, user, text, func(ctx context.Context) (string, error) {
+		if _, err := updateRemnawaveUser(ctx, UpdateUserRequest{UUID: user.UUID, ExpireAt: expireAt.UTC().Format(time.RFC3339)}); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("✅ `%s` продлён на %d дней\n📅 Истекает: *%s*", user.Username, days, expireAt.Format("02.01.2006")), nil
+	})
+}
+
+func runDisableCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message, args *CommandArgs) error {
+	user, err := getUserByUsername(ctx, args.Positional[0])
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("⛔ Отключить `%s`?\nСтатус: *%s*", user.Username, user.Status)
+	return confirmEdit(ctx, bot, msg.Chat.ID, msg.From.ID, user, text, func(ctx context.Context) (string, error) {
+		if err := setUserEnabled(ctx, user.UUID, false); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("⛔ `%s` отключён", user.Username), nil
+	})
+}
+
+func runEnableCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message, args *CommandArgs) error {
+	user, err := getUserByUsername(ctx, args.Positional[0])
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("✅ Включить `%s`?\nСтатус: *%s*", user.Username, user.Status)
+	return confirmEdit(ctx, bot, msg.Chat.ID, msg.From.ID, user, text, func(ctx context.Context) (string, error) {
+		if err := setUserEnabled(ctx, user.UUID, true); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("✅ `%s` включён", user.Username), nil
+	})
+}
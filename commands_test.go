@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommandArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         string
+		positional []string
+		flags      map[string]string
+		err        string
+	}{
+		{name: "empty", in: "", flags: map[string]string{}},
+		{name: "positional", in: "ivan 30  50", positional: []string{"ivan", "30", "50"}, flags: map[string]string{}},
+		{name: "tabs and newlines", in: "ivan\t30\n50", positional: []string{"ivan", "30", "50"}, flags: map[string]string{}},
+		{name: "double quotes", in: `"Иван Петров" 30`, positional: []string{"Иван Петров", "30"}, flags: map[string]string{}},
+		{name: "single quotes", in: `'it "works"' 30`, positional: []string{`it "works"`, "30"}, flags: map[string]string{}},
+		{name: "empty quotes", in: `"" 30`, positional: []string{"", "30"}, flags: map[string]string{}},
+		{name: "flag with value", in: "ivan --note vip 30", positional: []string{"ivan", "30"}, flags: map[string]string{"note": "vip"}},
+		{name: "flag with equals", in: "ivan --devices=3", positional: []string{"ivan"}, flags: map[string]string{"devices": "3"}},
+		{name: "quoted flag value", in: `--note "старый клиент"`, flags: map[string]string{"note": "старый клиент"}},
+		{name: "empty value after equals", in: "--note=", flags: map[string]string{"note": ""}},
+		{name: "unclosed quote", in: `"ivan 30`, err: "незакрытая кавычка"},
+		{name: "empty flag name", in: "ivan -- 30", err: "пустое имя флага"},
+		{name: "empty flag name with equals", in: "--=3", err: "пустое имя флага"},
+		{name: "missing flag value", in: "ivan --note", err: "флаг --note требует значение"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := parseCommandArgs(tt.in)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args.Positional, tt.positional) {
+				t.Errorf("positional %q, want %q", args.Positional, tt.positional)
+			}
+			if !reflect.DeepEqual(args.Flags, tt.flags) {
+				t.Errorf("flags %q, want %q", args.Flags, tt.flags)
+			}
+		})
+	}
+}
+
+func TestParseTrafficGB(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"50", 50, true},
+		{"100000", 100000, true},
+		{"100001", 0, false},
+		{"9223372036", 0, false},
+		{"-1", 0, false},
+		{"abc", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTrafficGB(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseTrafficGB(%q) = %d, %v", tt.in, got, err)
+		}
+	}
+}
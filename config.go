@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Optional JSON config file with settings that don't fit into env variables
+type Config struct {
+	Reports []ReportDefinition `json:"reports"`
+	Plans   []Plan             `json:"plans"`
+	Freeze  *FreezeLimits      `json:"freeze"` // defaults for plans without their own limits
+
+	UndoMinutes int               `json:"undoMinutes"` // how long a created client can be undone, 10 by default
+	LinkExpiry  *LinkExpiryConfig `json:"linkExpiry"`  // hiding of sent subscription links, kept forever when absent
+
+	AdminGroup *AdminGroupConfig `json:"adminGroup"` // the only group the bot works in
+
+	// Added to ADMIN_IDS, OWNER_IDS and SUPPORT_IDS, unlike them these can be changed without a restart
+	Admins  []int64 `json:"admins"`
+	Owners  []int64 `json:"owners"`
+	Support []int64 `json:"support"`
+
+	ReloadOnChange bool `json:"reloadOnChange"` // reload when the file changes, not only on SIGHUP
+
+	Encryption *EncryptionConfig `json:"encryption"` // data file encryption, plain JSON when absent
+}
+
+type ReportDefinition struct {
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`     // new_clients, revenue, squads, nodes
+	Schedule string  `json:"schedule"` // weekly, monthly or empty for on-demand only
+	Chats    []int64 `json:"chats"`
+}
+
+type Plan struct {
+	Name      string        `json:"name"`
+	TrafficGB int           `json:"trafficGb"`
+	Days      int           `json:"days"`
+	Price     float64       `json:"price"`
+	Currency  string        `json:"currency"`
+	Freeze    *FreezeLimits `json:"freeze"`
+}
+
+func loadConfig(path string) (*Config, error) {
+	c := &Config{}
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(raw, c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *Config) validate() error {
+	names := make(map[string]bool)
+	for _, r := range c.Reports {
+		if r.Name == "" {
+			return fmt.Errorf("report without name")
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate report %q", r.Name)
+		}
+		names[r.Name] = true
+
+		if _, ok := reportBuilders[r.Type]; !ok {
+			return fmt.Errorf("report %q: unknown type %q", r.Name, r.Type)
+		}
+		switch r.Schedule {
+		case "", "weekly", "monthly":
+		default:
+			return fmt.Errorf("report %q: unknown schedule %q", r.Name, r.Schedule)
+		}
+	}
+
+	for _, p := range c.Plans {
+		if p.Name == "" {
+			return fmt.Errorf("plan without name")
+		}
+		if p.Days <= 0 {
+			return fmt.Errorf("plan %q: days must be positive", p.Name)
+		}
+		if p.Freeze != nil && (p.Freeze.MaxFreezes < 0 || p.Freeze.MaxDays <= 0) {
+			return fmt.Errorf("plan %q: invalid freeze limits", p.Name)
+		}
+	}
+	if c.Freeze != nil && (c.Freeze.MaxFreezes < 0 || c.Freeze.MaxDays <= 0) {
+		return fmt.Errorf("invalid freeze limits")
+	}
+	if c.UndoMinutes < 0 {
+		return fmt.Errorf("undoMinutes must not be negative")
+	}
+	if c.LinkExpiry != nil {
+		if c.LinkExpiry.Minutes <= 0 {
+			return fmt.Errorf("linkExpiry: minutes must be positive")
+		}
+		switch c.LinkExpiry.Mode {
+		case "", "edit", "delete":
+		default:
+			return fmt.Errorf("linkExpiry: unknown mode %q", c.LinkExpiry.Mode)
+		}
+	}
+	if c.AdminGroup != nil && c.AdminGroup.ChatID >= 0 {
+		return fmt.Errorf("adminGroup: chatId must be a group chat ID")
+	}
+	if c.Encryption != nil && c.Encryption.KeyFile == "" {
+		return fmt.Errorf("encryption: keyFile is required")
+	}
+	return nil
+}
+
+// planFor finds the plan matching the chosen traffic and duration
+func (c *Config) planFor(trafficGB, days int) *Plan {
+	for i := range c.Plans {
+		if c.Plans[i].TrafficGB == trafficGB && c.Plans[i].Days == days {
+			return &c.Plans[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Encryption at rest for the data file: AES-256-GCM with keys read from secret files.
+// Older keys stay readable for rotation, the store is re-encrypted with the current key on open.
+const encryptedFormat = "botik-aes-gcm-v1"
+
+type EncryptionConfig struct {
+	KeyFile          string   `json:"keyFile"`          // base64 of 32 random bytes, see "botik genkey"
+	PreviousKeyFiles []string `json:"previousKeyFiles"` // still accepted for reading
+}
+
+type storeKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+type StoreKeys struct {
+	current *storeKey
+	byID    map[string]*storeKey
+}
+
+// encryptedFile is the on-disk envelope; a plain store never has the format field
+type encryptedFile struct {
+	Format string `json:"format"`
+	KeyID  string `json:"keyId"`
+	Nonce  []byte `json:"nonce"`
+	Data   []byte `json:"data"`
+}
+
+func readStoreKey(path string) (*storeKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s: expected base64 of a 32-byte key", path)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ID tells which key sealed a file without revealing the key
+	sum := sha256.Sum256(key)
+	return &storeKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// loadStoreKeys returns nil when encryption is not configured
+func loadStoreKeys(c *EncryptionConfig) (*StoreKeys, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	current, err := readStoreKey(c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	keys := &StoreKeys{current: current, byID: map[string]*storeKey{current.id: current}}
+	for _, path := range c.PreviousKeyFiles {
+		k, err := readStoreKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys.byID[k.id] = k
+	}
+	return keys, nil
+}
+
+func (k *StoreKeys) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, k.current.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	env := encryptedFile{Format: encryptedFormat, KeyID: k.current.id, Nonce: nonce}
+	env.Data = k.current.aead.Seal(nil, nonce, plain, []byte(encryptedFormat+":"+env.KeyID))
+	return json.MarshalIndent(&env, "", "  ")
+}
+
+// open decrypts an envelope and passes plain stores through. reseal reports that the
+// file is not yet sealed with the current key: it is plain or uses a previous key.
+func (k *StoreKeys) open(raw []byte) (plain []byte, reseal bool, err error) {
+	var env encryptedFile
+	if err := json.Unmarshal(raw, &env); err != nil || env.Format == "" {
+		return raw, k != nil, nil
+	}
+	if env.Format != encryptedFormat {
+		return nil, false, fmt.Errorf("unknown data file format %q", env.Format)
+	}
+	if k == nil {
+		return nil, false, fmt.Errorf("data file is encrypted but no encryption key is configured")
+	}
+
+	key, ok := k.byID[env.KeyID]
+	if !ok {
+		return nil, false, fmt.Errorf("data file is encrypted with unknown key %s", env.KeyID)
+	}
+	plain, err = key.aead.Open(nil, env.Nonce, env.Data, []byte(encryptedFormat+":"+env.KeyID))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decrypt data file: %w", err)
+	}
+	return plain, key != k.current, nil
+}
+
+func generateStoreKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
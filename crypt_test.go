@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestKey(t *testing.T, name string) string {
+	t.Helper()
+	key, err := generateStoreKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testKeys(t *testing.T, keyFile string, previous ...string) *StoreKeys {
+	t.Helper()
+	keys, err := loadStoreKeys(&EncryptionConfig{KeyFile: keyFile, PreviousKeyFiles: previous})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func sealedKeyID(t *testing.T, sealed []byte) string {
+	t.Helper()
+	var env encryptedFile
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		t.Fatal(err)
+	}
+	return env.KeyID
+}
+
+func TestStoreKeysRoundTrip(t *testing.T) {
+	oldKey, newKey := writeTestKey(t, "old.key"), writeTestKey(t, "new.key")
+	plain := []byte(`{"botUsers":{}}`)
+
+	// A plain store passes through and asks to be sealed
+	keys := testKeys(t, oldKey)
+	got, reseal, err := keys.open(plain)
+	if err != nil || !reseal || !bytes.Equal(got, plain) {
+		t.Fatalf("plain open: %q, reseal %v, err %v", got, reseal, err)
+	}
+	sealed, err := keys.seal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("botUsers")) {
+		t.Fatal("sealed file contains the plain data")
+	}
+	if got, reseal, err := keys.open(sealed); err != nil || reseal || !bytes.Equal(got, plain) {
+		t.Fatalf("sealed open: %q, reseal %v, err %v", got, reseal, err)
+	}
+
+	// After rotation the old file is still readable and gets resealed with the new key
+	rotated := testKeys(t, newKey, oldKey)
+	got, reseal, err = rotated.open(sealed)
+	if err != nil || !reseal || !bytes.Equal(got, plain) {
+		t.Fatalf("previous key open: %q, reseal %v, err %v", got, reseal, err)
+	}
+	resealed, err := rotated.seal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealedKeyID(t, resealed) != rotated.current.id {
+		t.Fatal("resealed file doesn't use the current key")
+	}
+	if got, reseal, err := rotated.open(resealed); err != nil || reseal || !bytes.Equal(got, plain) {
+		t.Fatalf("resealed open: %q, reseal %v, err %v", got, reseal, err)
+	}
+
+	// Without the old key the file can't be read
+	if _, _, err := testKeys(t, newKey).open(sealed); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Fatalf("unknown key: err %v", err)
+	}
+}
+
+func TestStoreKeysTampered(t *testing.T) {
+	keys := testKeys(t, writeTestKey(t, "store.key"))
+	sealed, err := keys.seal([]byte(`{"botUsers":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var env encryptedFile
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		t.Fatal(err)
+	}
+	env.Data[0] ^= 1
+	tampered, _ := json.Marshal(&env)
+
+	if _, _, err := keys.open(tampered); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Fatalf("tampered data: err %v", err)
+	}
+}
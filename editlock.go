@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Per-client edit locks. A change takes a short lease on the client when its screen or flow
+// opens, so a second admin acting on the same request is told who is already on it instead
+// of applying it twice. The lease remembers the client as the holder saw it, and the change
+// is applied only if the client still looks that way.
+const editLockTTL = 2 * time.Minute
+
+type EditLock struct {
+	HolderID int64
+	Until    time.Time
+	Version  string // clientVersion when the change was opened
+
+	// Set by confirmEdit, run when the change is confirmed
+	username string
+	confirm  func(ctx context.Context) (string, error)
+}
+
+var (
+	editLocks   = make(map[string]EditLock)
+	editLocksMu sync.Mutex
+)
+
+// lockClient takes or renews the lease, returning the other holder when it's busy
+func lockClient(uuid string, userID int64, version string) (EditLock, bool) {
+	editLocksMu.Lock()
+	defer editLocksMu.Unlock()
+
+	for id, l := range editLocks {
+		if time.Now().After(l.Until) {
+			delete(editLocks, id)
+		}
+	}
+	if l, ok := editLocks[uuid]; ok && l.HolderID != userID {
+		return l, false
+	}
+	editLocks[uuid] = EditLock{HolderID: userID, Until: time.Now().Add(editLockTTL), Version: version}
+	return EditLock{}, true
+}
+
+// clientLocked tells background jobs to leave a client alone for now
+func clientLocked(uuid string) bool {
+	editLocksMu.Lock()
+	defer editLocksMu.Unlock()
+
+	l, ok := editLocks[uuid]
+	return ok && time.Now().Before(l.Until)
+}
+
+// heldLock returns the lease if userID still holds it
+func heldLock(uuid string, userID int64) (EditLock, bool) {
+	editLocksMu.Lock()
+	defer editLocksMu.Unlock()
+
+	l, ok := editLocks[uuid]
+	if !ok || l.HolderID != userID || time.Now().After(l.Until) {
+		return EditLock{}, false
+	}
+	return l, true
+}
+
+func botUserName(userID int64) string {
+	name := strconv.FormatInt(userID, 10)
+	store.view(func(d *storeData) {
+		if u, ok := d.BotUsers[userID]; ok {
+			switch {
+			case u.Username != "":
+				name = "@" + u.Username
+			case u.FirstName != "":
+				name = strings.TrimSpace(u.FirstName + " " + u.LastName)
+			}
+		}
+	})
+	return name
+}
+
+// clientVersion fingerprints the fields the bot changes, to notice edits made in between
+func clientVersion(u *RemnawaveUser) string {
+	squads := make([]string, 0, len(u.ActiveInternalSquads))
+	for _, sq := range u.ActiveInternalSquads {
+		squads = append(squads, sq.UUID)
+	}
+	sort.Strings(squads)
+
+	var telegramID int64
+	if u.TelegramID != nil {
+		telegramID = *u.TelegramID
+	}
+	return fmt.Sprintf("%s|%s|%d|%d|%s", u.ExpireAt.UTC().Format(time.RFC3339), u.Status, u.TrafficLimitBytes, telegramID, strings.Join(squads, ","))
+}
+
+func busyError(username string, holder EditLock) error {
+	return fmt.Errorf("клиента %s сейчас редактирует %s, попробуйте через %d мин.",
+		username, botUserName(holder.HolderID), int(time.Until(holder.Until).Minutes())+1)
+}
+
+// beginEdit takes the lease when a change is opened, remembering the client as shown
+func beginEdit(user *RemnawaveUser, userID int64) error {
+	if holder, ok := lockClient(user.UUID, userID, clientVersion(user)); !ok {
+		return busyError(user.Username, holder)
+	}
+	return nil
+}
+
+// endEdit releases the lease once the change is applied or cancelled
+func endEdit(uuid string, userID int64) {
+	editLocksMu.Lock()
+	defer editLocksMu.Unlock()
+
+	if l, ok := editLocks[uuid]; ok && l.HolderID == userID {
+		delete(editLocks, uuid)
+	}
+}
+
+// finishEdit applies a change opened with beginEdit, if the lease is still held and the
+// client hasn't changed since. The lease is released either way.
+func finishEdit(ctx context.Context, user *RemnawaveUser, userID int64, apply func() error) error {
+	l, ok := heldLock(user.UUID, userID)
+	if !ok {
+		return fmt.Errorf("время на подтверждение истекло, откройте действие заново")
+	}
+	return applyLocked(ctx, user, l.Version, userID, apply)
+}
+
+// openEditError refuses a separate change while the same holder has one opened on the
+// client: renewing and releasing the lease would throw that one away
+func openEditError(user *RemnawaveUser, userID int64) error {
+	if _, ok := heldLock(user.UUID, userID); ok {
+		return fmt.Errorf("у вас открыто другое действие с клиентом %s, подтвердите или отмените его", user.Username)
+	}
+	return nil
+}
+
+// applyEdit applies a change decided on when the client had the given version, under the
+// client's lease. The lease is released afterwards.
+func applyEdit(ctx context.Context, user *RemnawaveUser, version string, userID int64, apply func() error) error {
+	if err := openEditError(user, userID); err != nil {
+		return err
+	}
+	return applyLocked(ctx, user, version, userID, apply)
+}
+
+func applyLocked(ctx context.Context, user *RemnawaveUser, version string, userID int64, apply func() error) error {
+	if holder, ok := lockClient(user.UUID, userID, version); !ok {
+		return busyError(user.Username, holder)
+	}
+	defer endEdit(user.UUID, userID)
+
+	current, err := getUserByUUID(ctx, user.UUID)
+	if err != nil {
+		return err
+	}
+	if clientVersion(current) != version {
+		return fmt.Errorf("клиент %s изменился, пока готовилось действие, проверьте его и повторите", user.Username)
+	}
+	return apply()
+}
+
+// editClient applies a one-step change under the client's lease. There is no earlier view
+// to compare with, so apply itself checks the state it relies on.
+func editClient(user *RemnawaveUser, userID int64, apply func() error) error {
+	if err := openEditError(user, userID); err != nil {
+		return err
+	}
+	if err := beginEdit(user, userID); err != nil {
+		return err
+	}
+	defer endEdit(user.UUID, userID)
+	return apply()
+}
+
+// confirmEdit opens a change behind a confirmation screen: the lease is taken now and the
+// change runs on "edit_ok_<uuid>" against the client as shown. apply returns the Markdown
+// result to send.
+func confirmEdit(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, user *RemnawaveUser, text string, apply func(ctx context.Context) (string, error)) error {
+	if err := beginEdit(user, userID); err != nil {
+		return err
+	}
+	editLocksMu.Lock()
+	l := editLocks[user.UUID]
+	l.username, l.confirm = user.Username, apply
+	editLocks[user.UUID] = l
+	editLocksMu.Unlock()
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить", "edit_ok_"+user.UUID),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "edit_no_"+user.UUID),
+		),
+	)
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+	return nil
+}
+
+// handleEditCallback confirms or cancels a change opened with confirmEdit
+func handleEditCallback(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
+	action, uuid, _ := strings.Cut(strings.TrimPrefix(data, "edit_"), "_")
+	if action != "ok" {
+		endEdit(uuid, userID)
+		bot.Send(tgbotapi.NewMessage(chatID, "✖️ Действие отменено."))
+		return
+	}
+
+	l, ok := heldLock(uuid, userID)
+	if !ok || l.confirm == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "⌛ Время на подтверждение истекло, повторите команду."))
+		return
+	}
+
+	var result string
+	err := finishEdit(ctx, &RemnawaveUser{UUID: uuid, Username: l.username}, userID, func() error {
+		var err error
+		result, err = l.confirm(ctx)
+		return err
+	})
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, result)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
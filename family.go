@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Family plans: an owner hands out sub-clients within a shared traffic quota
+type Family struct {
+	OwnerID    int64           `json:"ownerId"`
+	MaxMembers int             `json:"maxMembers"`
+	TrafficGB  int             `json:"trafficGb"` // shared quota, 0 for unlimited
+	ExpireAt   time.Time       `json:"expireAt"`
+	Members    []*FamilyMember `json:"members"`
+}
+
+type FamilyMember struct {
+	UUID      string `json:"uuid"`
+	Username  string `json:"username"`
+	TrafficGB int    `json:"trafficGb"`
+}
+
+func (f *Family) allocatedGB() int {
+	total := 0
+	for _, m := range f.Members {
+		total += m.TrafficGB
+	}
+	return total
+}
+
+func (f *Family) member(uuid string) *FamilyMember {
+	for _, m := range f.Members {
+		if m.UUID == uuid {
+			return m
+		}
+	}
+	return nil
+}
+
+func familyOf(ownerID int64) *Family {
+	var family *Family
+	store.view(func(d *storeData) {
+		if f, ok := d.Families[ownerID]; ok {
+			copied := *f
+			copied.Members = append([]*FamilyMember(nil), f.Members...)
+			family = &copied
+		}
+	})
+	return family
+}
+
+// familyUsername keeps member names unique across owners
+func familyUsername(ownerID int64, name string) string {
+	return fmt.Sprintf("fam%d-%s", ownerID, name)
+}
+
+func runFamilyCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message, args *CommandArgs) error {
+	ownerID, err := strconv.ParseInt(args.Positional[0], 10, 64)
+	if err != nil || ownerID <= 0 {
+		return fmt.Errorf("ожидается Telegram ID владельца")
+	}
+	members, err := strconv.Atoi(args.Positional[1])
+	if err != nil || members <= 0 || members > 20 {
+		return fmt.Errorf("участников должно быть от 1 до 20")
+	}
+	trafficGB, err := parseTrafficGB(args.Positional[2])
+	if err != nil {
+		return err
+	}
+	days, err := parseDays(args.Positional[3])
+	if err != nil {
+		return err
+	}
+
+	family := &Family{
+		OwnerID:    ownerID,
+		MaxMembers: members,
+		TrafficGB:  trafficGB,
+		ExpireAt:   time.Now().AddDate(0, 0, days),
+	}
+	if existing := familyOf(ownerID); existing != nil {
+		family.Members = existing.Members
+	}
+
+	if err := store.update(func(d *storeData) { d.Families[ownerID] = family }); err != nil {
+		return err
+	}
+
+	// A renewal moves the members' subscriptions along with the plan
+	var failed []string
+	for _, member := range family.Members {
+		err := editClient(&RemnawaveUser{UUID: member.UUID, Username: member.Username}, msg.From.ID, func() error {
+			_, err := updateRemnawaveUser(ctx, UpdateUserRequest{UUID: member.UUID, ExpireAt: family.ExpireAt.UTC().Format(time.RFC3339)})
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to extend family member %s: %v", member.Username, err)
+			failed = append(failed, member.Username)
+		}
+	}
+
+	text := fmt.Sprintf(
+		"👨‍👩‍👧 Семейный план для `%d`\nУчастников: *%d*\nТрафик: *%s*\n📅 До: *%s*",
+		ownerID, members, trafficText(trafficGB), family.ExpireAt.Format("02.01.2006"),
+	)
+	if len(family.Members) > 0 {
+		text += fmt.Sprintf("\nПродлено участников: *%d из %d*", len(family.Members)-len(failed), len(family.Members))
+	}
+	if len(failed) > 0 {
+		text += "\n❌ Не продлены: `" + codeText(strings.Join(failed, ", ")) + "`"
+	}
+	m := tgbotapi.NewMessage(msg.Chat.ID, text)
+	m.ParseMode = "Markdown"
+	bot.Send(m)
+
+	notifyUser(ctx, bot, ownerID, notifySubscription, "👨‍👩‍👧 Вам доступен семейный план. Откройте «Мои подписки» → «Моя семья».")
+	return nil
+}
+
+func handleFamilyCallback(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
+	family := familyOf(userID)
+	if family == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "У вас нет семейного плана."))
+		return
+	}
+
+	switch {
+	case data == "family":
+		sendFamilyView(ctx, bot, chatID, family)
+
+	case data == "family_add":
+		handleFamilyAdd(ctx, bot, chatID, userID, family)
+
+	case strings.HasPrefix(data, "family_regen_"):
+		handleFamilyRegenerate(ctx, bot, chatID, family, strings.TrimPrefix(data, "family_regen_"))
+
+	case strings.HasPrefix(data, "family_revoke_"):
+		uuid := strings.TrimPrefix(data, "family_revoke_")
+		m := family.member(uuid)
+		if m == nil {
+			return
+		}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", "family_delete_"+uuid),
+				tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "family"),
+			),
+		)
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Удалить участника `%s`? Его ссылка перестанет работать.", m.Username))
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = keyboard
+		bot.Send(msg)
+
+	case strings.HasPrefix(data, "family_delete_"):
+		handleFamilyDelete(ctx, bot, chatID, userID, family, strings.TrimPrefix(data, "family_delete_"))
+	}
+}
+
+// addFamilyButton inserts the family entry point above the back button for family owners
+func addFamilyButton(keyboard *tgbotapi.InlineKeyboardMarkup, userID int64) {
+	if familyOf(userID) == nil {
+		return
+	}
+	rows := keyboard.InlineKeyboard
+	row := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("👨‍👩‍👧 Моя семья", "family"),
+	)
+	keyboard.InlineKeyboard = append(rows[:len(rows)-1:len(rows)-1], row, rows[len(rows)-1])
+}
+
+func sendFamilyView(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, family *Family) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "👨‍👩‍👧 *Моя семья*\n\nУчастники: *%d из %d*\n", len(family.Members), family.MaxMembers)
+	if family.TrafficGB > 0 {
+		fmt.Fprintf(&sb, "Квота: *%d из %d GB* распределено\n", family.allocatedGB(), family.TrafficGB)
+	}
+	fmt.Fprintf(&sb, "📅 До: *%s*\n\n", family.ExpireAt.Format("02.01.2006"))
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, m := range family.Members {
+		user, err := getUserByUUID(ctx, m.UUID)
+		if err != nil {
+			log.Printf("Failed to get family member %s: %v", m.Username, err)
+			fmt.Fprintf(&sb, "• `%s` — нет данных\n", m.Username)
+		} else {
+			fmt.Fprintf(&sb, "• `%s` — %s из %s, %s\n",
+				m.Username, formatBytes(user.UsedTrafficBytes), trafficText(m.TrafficGB), user.Status)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 "+m.Username, "family_regen_"+m.UUID),
+			tgbotapi.NewInlineKeyboardButtonData("🗑", "family_revoke_"+m.UUID),
+		))
+	}
+
+	if len(family.Members) < family.MaxMembers {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ Добавить участника", "family_add"),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "my_subs"),
+	))
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bot.Send(msg)
+}
+
+func handleFamilyAdd(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, family *Family) {
+	if len(family.Members) >= family.MaxMembers {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Достигнут лимит участников."))
+		return
+	}
+	if time.Now().After(family.ExpireAt) {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Срок семейного плана истёк."))
+		return
+	}
+
+	if family.TrafficGB > 0 && family.TrafficGB-family.allocatedGB() <= 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Вся квота трафика уже распределена."))
+		return
+	}
+
+	startFlow(ctx, bot, chatID, userID, "family_member", nil)
+}
+
+// familyTrafficChoices offers a few fixed sizes below the remaining quota plus the whole remainder
+func familyTrafficChoices(family *Family) [][]Choice {
+	remaining := family.TrafficGB - family.allocatedGB()
+
+	var row []Choice
+	for _, gb := range []int{10, 25, 50, 100} {
+		if gb < remaining {
+			row = append(row, Choice{fmt.Sprintf("%d GB", gb), strconv.Itoa(gb)})
+		}
+	}
+	choices := [][]Choice{{{fmt.Sprintf("Весь остаток (%d GB)", remaining), strconv.Itoa(remaining)}}}
+	if len(row) > 0 {
+		choices = append([][]Choice{row}, choices...)
+	}
+	return choices
+}
+
+func finishFamilyMember(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, trafficGB int, name string) {
+	family := familyOf(userID)
+	if family == nil {
+		return
+	}
+
+	// Re-check limits, the family may have changed while the name was typed
+	if len(family.Members) >= family.MaxMembers ||
+		(family.TrafficGB > 0 && trafficGB > family.TrafficGB-family.allocatedGB()) {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Лимиты семейного плана исчерпаны."))
+		return
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, "⏳ Создаю участника..."))
+
+	user, err := createClient(ctx, ClientParams{
+		Name:      familyUsername(userID, name),
+		TrafficGB: trafficGB,
+		ExpireAt:  family.ExpireAt,
+		Family:    true, // the owner's ID would show members as the owner's own subscriptions
+	}, userID)
+	if err != nil {
+		log.Printf("Failed to create family member for %d: %v", userID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось создать участника. Возможно, имя уже занято."))
+		return
+	}
+
+	err = store.update(func(d *storeData) {
+		if f, ok := d.Families[userID]; ok {
+			f.Members = append(f.Members, &FamilyMember{UUID: user.UUID, Username: user.Username, TrafficGB: trafficGB})
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to save family member: %v", err)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👨‍👩‍👧 К семье", "family"),
+		),
+	)
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"✅ *Участник добавлен!*\n\n👤 `%s`\n📊 Трафик: *%s*\n\n🔗 *Ссылка:*\n`%s`",
+		user.Username, trafficText(trafficGB), subscriptionLink(user.ShortUUID),
+	))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	sendLinkMessage(bot, msg, user.UUID, user.Username)
+}
+
+func handleFamilyRegenerate(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, family *Family, uuid string) {
+	if family.member(uuid) == nil {
+		return
+	}
+
+	user, err := revokeUserSubscription(ctx, uuid)
+	if err != nil {
+		log.Printf("Failed to regenerate link for %s: %v", uuid, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось обновить ссылку."))
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👨‍👩‍👧 К семье", "family"),
+		),
+	)
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"🔄 Ссылка для `%s` обновлена, старая больше не работает.\n\n🔗 `%s`",
+		user.Username, subscriptionLink(user.ShortUUID),
+	))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	sendLinkMessage(bot, msg, user.UUID, user.Username)
+}
+
+func handleFamilyDelete(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, family *Family, uuid string) {
+	member := family.member(uuid)
+	if member == nil {
+		return
+	}
+
+	if err := editClient(&RemnawaveUser{UUID: uuid, Username: member.Username}, userID, func() error { return deleteRemnawaveUser(ctx, uuid) }); err != nil {
+		log.Printf("Failed to delete family member %s: %v", uuid, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось удалить участника."))
+		return
+	}
+
+	err := store.update(func(d *storeData) {
+		f, ok := d.Families[userID]
+		if !ok {
+			return
+		}
+		for i, m := range f.Members {
+			if m.UUID == uuid {
+				f.Members = append(f.Members[:i], f.Members[i+1:]...)
+				break
+			}
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to save family: %v", err)
+	}
+
+	if updated := familyOf(userID); updated != nil {
+		sendFamilyView(ctx, bot, chatID, updated)
+	}
+}
+
+func formatBytes(b int64) string {
+	const gb = 1024 * 1024 * 1024
+	if b >= gb {
+		return fmt.Sprintf("%.1f GB", float64(b)/gb)
+	}
+	return fmt.Sprintf("%.0f MB", float64(b)/(1024*1024))
+}
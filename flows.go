@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Conversation flows of the bot, run by the engine in wizard.go
+func init() {
+	flows = map[string]*Flow{
+		"create_client": {
+			Start:  "traffic",
+			Access: isAdmin,
+			Steps: map[string]*WizardStep{
+				"traffic": {
+					Input:  InputButtons,
+					Prompt: func(*WizardSession) string { return "📊 *Выберите лимит трафика:*" },
+					Choices: func(*WizardSession) [][]Choice {
+						return [][]Choice{
+							{{"50 GB", "50"}, {"100 GB", "100"}, {"200 GB", "200"}},
+							{{"500 GB", "500"}, {"♾ Безлимит", "0"}},
+						}
+					},
+					Chosen: func(s *WizardSession) string { return s.Values["traffic"] },
+					Apply: func(s *WizardSession, in WizardInput) error {
+						s.Values["traffic"] = in.Value
+						s.Params.TrafficGB, _ = strconv.Atoi(in.Value)
+						return nil
+					},
+					Next: goTo("expire"),
+				},
+				"expire": {
+					Input: InputButtons,
+					Prompt: func(s *WizardSession) string {
+						return fmt.Sprintf("📊 Трафик: *%s*\n\n⏳ *Выберите срок действия:*", trafficText(s.Params.TrafficGB))
+					},
+					Choices: func(*WizardSession) [][]Choice {
+						return [][]Choice{
+							{{"7 дней", "7"}, {"30 дней", "30"}, {"90 дней", "90"}},
+							{{"180 дней", "180"}, {"365 дней", "365"}},
+						}
+					},
+					Chosen: func(s *WizardSession) string { return s.Values["expire"] },
+					Apply: func(s *WizardSession, in WizardInput) error {
+						s.Values["expire"] = in.Value
+						s.Params.Days, _ = strconv.Atoi(in.Value)
+						return nil
+					},
+					Next: goTo("start"),
+				},
+				"start": {
+					Input: InputButtons,
+					Prompt: func(*WizardSession) string {
+						return "🕒 *Когда активировать подписку?*\n\nСрок действия считается с момента активации."
+					},
+					Choices: func(*WizardSession) [][]Choice {
+						return [][]Choice{
+							{{"▶️ Сразу", "now"}, {"Завтра", "tomorrow"}},
+							{{"1-го числа след. месяца", "month"}},
+							{{"📅 Ввести дату", "custom"}},
+						}
+					},
+					Chosen: func(s *WizardSession) string { return s.Values["start"] },
+					Apply: func(s *WizardSession, in WizardInput) error {
+						s.Values["start"] = in.Value
+						s.Params.StartAt = startDateFor(in.Value, time.Now())
+						return nil
+					},
+					Next: func(s *WizardSession) string {
+						if s.Values["start"] == "custom" {
+							return "start_date"
+						}
+						return "names"
+					},
+				},
+				"start_date": {
+					Input: InputText,
+					Prompt: func(*WizardSession) string {
+						return "📅 *Введите дату активации* в формате `ДД.ММ.ГГГГ`"
+					},
+					Apply: func(s *WizardSession, in WizardInput) error {
+						startAt, err := parseStartDate(in.Value, time.Now())
+						if err != nil {
+							return err
+						}
+						s.Params.StartAt = startAt
+						return nil
+					},
+					Next: goTo("names"),
+				},
+				"names": clientNamesStep(),
+			},
+			Finish: finishClientNames,
+		},
+
+		"clone_client": {
+			Start:  "names",
+			Access: isAdmin,
+			Steps: map[string]*WizardStep{
+				"names": clientNamesStep(),
+			},
+			Finish: finishClientNames,
+		},
+
+		"family_member": {
+			Start:  "traffic",
+			Access: func(userID int64) bool { return familyOf(userID) != nil },
+			Steps: map[string]*WizardStep{
+				"traffic": {
+					Input: InputButtons,
+					Prompt: func(*WizardSession) string {
+						return "📊 *Сколько трафика выделить участнику?*"
+					},
+					Choices: func(s *WizardSession) [][]Choice {
+						return familyTrafficChoices(familyOf(s.UserID))
+					},
+					// Unlimited family plans skip the traffic step
+					Skip: func(s *WizardSession) bool {
+						family := familyOf(s.UserID)
+						return family == nil || family.TrafficGB == 0
+					},
+					Apply: func(s *WizardSession, in WizardInput) error {
+						s.Params.TrafficGB, _ = strconv.Atoi(in.Value)
+						return nil
+					},
+					Next: goTo("name"),
+				},
+				"name": {
+					Input:  InputText,
+					Prompt: func(*WizardSession) string { return clientNamePrompt(false) },
+					Apply: func(s *WizardSession, in WizardInput) error {
+						name := strings.TrimSpace(in.Value)
+						if !validClientName(name) {
+							return fmt.Errorf("имя должно содержать только латиницу, цифры, дефис или подчёркивание")
+						}
+						s.Params.Name = name
+						return nil
+					},
+					Next: goTo(""),
+				},
+			},
+			Finish: func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+				finishFamilyMember(ctx, bot, s.ChatID, s.UserID, s.Params.TrafficGB, s.Params.Name)
+			},
+			Cancel: func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+				if family := familyOf(s.UserID); family != nil {
+					sendFamilyView(ctx, bot, s.ChatID, family)
+				}
+			},
+		},
+
+		"squad_create": {
+			Start:  "name",
+			Access: isAdmin,
+			Steps: map[string]*WizardStep{
+				"name": {
+					Input:  InputText,
+					Prompt: squadNamePrompt,
+					Apply:  applySquadName,
+					Next:   goTo("inbounds"),
+				},
+				"inbounds": {
+					Input: InputText,
+					Prompt: func(s *WizardSession) string {
+						text := "🔌 *Укажите инбаунды* через запятую, `all` — все, `-` — без инбаундов."
+						if inbounds, err := getInbounds(s.ctx); err == nil {
+							text += fmt.Sprintf("\n\nДоступные: `%s`", inboundTags(inbounds))
+						}
+						return text
+					},
+					Apply: func(s *WizardSession, in WizardInput) error {
+						inbounds, err := getInbounds(s.ctx)
+						if err != nil {
+							return fmt.Errorf("не удалось получить инбаунды: %v", err)
+						}
+						uuids, err := parseInboundSelection(in.Value, inbounds)
+						if err != nil {
+							return err
+						}
+						s.Values["inbounds"] = strings.Join(uuids, ",")
+						return nil
+					},
+					Next: goTo(""),
+				},
+			},
+			Finish: finishSquadCreate,
+			Cancel: func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) { sendSquadsMenu(ctx, bot, s.ChatID) },
+		},
+
+		"squad_rename": {
+			Start:  "name",
+			Access: isAdmin,
+			Steps: map[string]*WizardStep{
+				"name": {
+					Input:  InputText,
+					Prompt: squadNamePrompt,
+					Apply:  applySquadName,
+					Next:   goTo(""),
+				},
+			},
+			Finish: finishSquadRename,
+			Cancel: cancelToSquad,
+		},
+
+		"squad_move": {
+			Start:  "users",
+			Access: isAdmin,
+			Steps: map[string]*WizardStep{
+				"users": {
+					Input: InputText,
+					Prompt: func(*WizardSession) string {
+						return "👤 *Введите имена пользователей* через запятую или с новой строки."
+					},
+					Apply: func(s *WizardSession, in WizardInput) error {
+						names, invalid := parseClientNames(in.Value)
+						switch {
+						case len(invalid) > 0:
+							return fmt.Errorf("неверные имена: %s", strings.Join(invalid, ", "))
+						case len(names) == 0:
+							return fmt.Errorf("укажите хотя бы одно имя")
+						case len(names) > maxBatchNames:
+							return fmt.Errorf("не больше %d имён за раз", maxBatchNames)
+						}
+						if err := leaseSquadMove(s, names); err != nil {
+							return err
+						}
+						s.Names = names
+						return nil
+					},
+					Next: goTo("target"),
+				},
+				"target": {
+					Input: InputButtons,
+					Prompt: func(s *WizardSession) string {
+						return fmt.Sprintf("🔀 *Куда перенести* (%d)?", len(s.Names))
+					},
+					Choices: squadTargetChoices,
+					Apply: func(s *WizardSession, in WizardInput) error {
+						s.Values["to"] = in.Value
+						return nil
+					},
+					Next: goTo(""),
+				},
+			},
+			Finish: finishSquadMove,
+			Cancel: cancelSquadMove,
+		},
+
+		"host_remark": {
+			Start:  "remark",
+			Access: isAdmin,
+			Steps: map[string]*WizardStep{
+				"remark": {
+					Input: InputText,
+					Prompt: func(*WizardSession) string {
+						return fmt.Sprintf("✏️ *Введите новое название хоста* (до %d символов).\n\nЕго увидят клиенты в списке локаций.", maxHostRemark)
+					},
+					Apply: applyHostRemark,
+					Next:  goTo(""),
+				},
+			},
+			Finish: finishHostRemark,
+			Cancel: func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+				sendHostView(ctx, bot, s.ChatID, s.Values["host"])
+			},
+		},
+
+		"claim_link": {
+			Start: "link",
+			Steps: map[string]*WizardStep{
+				"link": {
+					Input: InputText,
+					Prompt: func(*WizardSession) string {
+						return "🔗 *Отправьте ссылку на вашу подписку.*\n\nНапример: `https://sub.example.com/api/sub/abc123`"
+					},
+					Apply: func(s *WizardSession, in WizardInput) error {
+						shortUUID := shortUUIDFromLink(in.Value)
+						if shortUUID == "" {
+							return fmt.Errorf("не удалось распознать ссылку")
+						}
+						s.Values["shortUuid"] = shortUUID
+						return nil
+					},
+					Next: goTo(""),
+				},
+			},
+			Finish: func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+				finishClaimLink(ctx, bot, s.ChatID, s.UserID, s.Values["shortUuid"])
+			},
+			Cancel: func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+				handleMySubs(ctx, bot, s.ChatID, s.UserID)
+			},
+		},
+
+		"link_csv": {
+			Start:  "file",
+			Access: isOwner,
+			Steps: map[string]*WizardStep{
+				"file": {
+					Input: InputDocument,
+					Prompt: func(*WizardSession) string {
+						return "📄 *Отправьте CSV-файл* со строками `username,telegram_id`."
+					},
+					Apply: func(s *WizardSession, in WizardInput) error {
+						s.Document = in.Document
+						return nil
+					},
+					Next: goTo(""),
+				},
+			},
+			Finish: func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+				handleLinkCSV(ctx, bot, s.ChatID, s.UserID, s.Document)
+			},
+			Cancel: func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+				sendLinkMenu(ctx, bot, s.ChatID)
+			},
+		},
+
+		"quiet_hours": {
+			Start: "hours",
+			Steps: map[string]*WizardStep{
+				"hours": {
+					Input: InputText,
+					Prompt: func(*WizardSession) string {
+						return "🌙 *Введите тихие часы* в формате `23-8`\nили `off`, чтобы отключить."
+					},
+					Apply: func(s *WizardSession, in WizardInput) error {
+						if _, _, err := parseQuietHours(in.Value); err != nil {
+							return err
+						}
+						s.Values["hours"] = in.Value
+						return nil
+					},
+					Next: goTo(""),
+				},
+			},
+			Finish: func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+				from, to, _ := parseQuietHours(s.Values["hours"])
+				updatePrefs(s.UserID, func(p *NotifyPrefs) {
+					p.QuietFrom = from
+					p.QuietTo = to
+				})
+				sendSettingsMenu(ctx, bot, s.ChatID, s.UserID)
+			},
+			Cancel: cancelToSettings,
+		},
+
+		"timezone": {
+			Start: "tz",
+			Steps: map[string]*WizardStep{
+				"tz": {
+					Input: InputText,
+					Prompt: func(*WizardSession) string {
+						return "🌍 *Введите часовой пояс*, например `Europe/Moscow` или `Asia/Yekaterinburg`."
+					},
+					Apply: func(s *WizardSession, in WizardInput) error {
+						tz, err := parseTimezone(in.Value)
+						if err != nil {
+							return err
+						}
+						s.Values["tz"] = tz
+						return nil
+					},
+					Next: goTo(""),
+				},
+			},
+			Finish: func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+				updatePrefs(s.UserID, func(p *NotifyPrefs) { p.Timezone = s.Values["tz"] })
+				sendSettingsMenu(ctx, bot, s.ChatID, s.UserID)
+			},
+			Cancel: cancelToSettings,
+		},
+	}
+}
+
+func cancelToSquad(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+	sendSquadView(ctx, bot, s.ChatID, s.Values["squad"])
+}
+
+func cancelToSettings(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+	sendSettingsMenu(ctx, bot, s.ChatID, s.UserID)
+}
+
+func clientNamePrompt(allowMultiple bool) string {
+	text := "✏️ *Введите имя для клиента:*\n\nТолько латиница, цифры, дефис и подчёркивание.\nНапример: `Ivan` или `iPhone-Petya`"
+	if allowMultiple {
+		text += "\n\nМожно указать несколько имён через запятую или с новой строки — клиенты будут созданы с одинаковыми настройками."
+	}
+	return text
+}
+
+// clientNamesStep asks for one or several client names sharing the collected settings
+func clientNamesStep() *WizardStep {
+	return &WizardStep{
+		Input: InputText,
+		Prompt: func(s *WizardSession) string {
+			text := clientNamePrompt(true)
+			if s.Params.Name != "" {
+				// Reopened after an undo
+				text += fmt.Sprintf("\n\nПрежнее имя: `%s`", s.Params.Name)
+			}
+			return text
+		},
+		Apply: func(s *WizardSession, in WizardInput) error {
+			names, invalid := parseClientNames(in.Value)
+			switch {
+			case len(invalid) > 0 && len(names)+len(invalid) > 1:
+				return fmt.Errorf("неверные имена: %s", strings.Join(invalid, ", "))
+			case len(names) == 0 || len(invalid) > 0:
+				return fmt.Errorf("имя должно содержать только латиницу, цифры, дефис или подчёркивание")
+			case len(names) > maxBatchNames:
+				return fmt.Errorf("не больше %d имён за раз", maxBatchNames)
+			}
+			s.Names = names
+			return nil
+		},
+		Next: goTo(""),
+	}
+}
+
+func finishClientNames(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+	if len(s.Names) > 1 {
+		finishBatchCreation(ctx, bot, s.ChatID, s.UserID, s.Names, s.Params)
+		return
+	}
+	params := s.Params
+	params.Name = s.Names[0]
+	finishClientCreation(ctx, bot, s.ChatID, s.UserID, params)
+}
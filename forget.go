@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Forgetting a user on request: local data is removed, records kept for accounting lose the
+// Telegram ID, and the panel subscriptions can optionally be unlinked from it too
+
+type ForgetResult struct {
+	Removed  int // local records deleted or stripped of the ID
+	Unlinked int // panel users unlinked from the Telegram ID
+}
+
+func sendForgetConfirm(ctx context.Context, bot *tgbotapi.BotAPI, chatID, targetID int64, self bool) {
+	text := "🗑 *Удаление данных*\n\n" +
+		"Бот забудет ваши настройки уведомлений, незавершённые действия и семейный план.\n\n" +
+		"Подписки можно отвязать от вашего Telegram: сами подписки продолжат работать, " +
+		"но бот перестанет их показывать и присылать по ним уведомления."
+	if !self {
+		text = fmt.Sprintf("🗑 *Удаление данных пользователя* `%d`\n\n"+
+			"Будут удалены настройки, незавершённые действия и семейный план. "+
+			"Подписки можно также отвязать от его Telegram.", targetID)
+	}
+
+	id := strconv.FormatInt(targetID, 10)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить данные", "forget_keep_"+id),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить и отвязать подписки", "forget_unlink_"+id),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "main_menu"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+func runForgetCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	targetID, err := strconv.ParseInt(strings.TrimSpace(msg.CommandArguments()), 10, 64)
+	if err != nil || targetID <= 0 {
+		m := tgbotapi.NewMessage(msg.Chat.ID, "Использование: `/forget <tg_id>`")
+		m.ParseMode = "Markdown"
+		bot.Send(m)
+		return
+	}
+	sendForgetConfirm(ctx, bot, msg.Chat.ID, targetID, false)
+}
+
+func handleForgetCallback(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
+	mode, id, _ := strings.Cut(strings.TrimPrefix(data, "forget_"), "_")
+	targetID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return
+	}
+	self := targetID == userID
+	if !self && !isOwner(userID) {
+		bot.Send(tgbotapi.NewMessage(chatID, "⛔ У вас нет доступа."))
+		return
+	}
+
+	result, err := forgetUser(ctx, targetID, mode == "unlink")
+	if err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка удаления данных:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+
+	// The entry must not identify whom it was about, a self-request doesn't name the actor either
+	actorID, target := int64(0), "по запросу пользователя"
+	if !self {
+		actorID, target = userID, "по команде владельца"
+	}
+	recordAudit(actorID, "forget_user", target, fmt.Sprintf("%d records, %d subscriptions unlinked", result.Removed, result.Unlinked))
+	log.Printf("Forgot a user: %d records, %d subscriptions unlinked", result.Removed, result.Unlinked)
+
+	text := fmt.Sprintf("✅ Данные удалены, записей: %d.", result.Removed)
+	if mode == "unlink" {
+		text += fmt.Sprintf("\nОтвязано подписок: %d.", result.Unlinked)
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+}
+
+// forgetUser removes everything the bot keeps about a Telegram user
+func forgetUser(ctx context.Context, telegramID int64, unlink bool) (ForgetResult, error) {
+	var result ForgetResult
+
+	// Unlink first: if the panel fails, the user can retry while the bot still knows them
+	if unlink {
+		users, err := getAllUsers(ctx)
+		if err != nil {
+			return result, err
+		}
+		for _, u := range users {
+			if u.TelegramID == nil || *u.TelegramID != telegramID {
+				continue
+			}
+			if err := editClient(&u, telegramID, func() error { return unlinkTelegramID(ctx, u.UUID) }); err != nil {
+				return result, err
+			}
+			result.Unlinked++
+		}
+	}
+
+	err := store.update(func(d *storeData) {
+		if _, ok := d.BotUsers[telegramID]; ok {
+			delete(d.BotUsers, telegramID)
+			result.Removed++
+		}
+		if _, ok := d.Preferences[telegramID]; ok {
+			delete(d.Preferences, telegramID)
+			result.Removed++
+		}
+		if _, ok := d.Families[telegramID]; ok {
+			delete(d.Families, telegramID)
+			result.Removed++
+		}
+		for key, s := range d.Wizards {
+			if s.UserID == telegramID || s.ChatID == telegramID {
+				delete(d.Wizards, key)
+				result.Removed++
+			}
+		}
+
+		var notifications []*PendingNotification
+		for _, n := range d.PendingNotifications {
+			if n.UserID == telegramID {
+				result.Removed++
+				continue
+			}
+			notifications = append(notifications, n)
+		}
+		d.PendingNotifications = notifications
+
+		var links []*LinkMessage
+		for _, m := range d.LinkMessages {
+			if m.ChatID == telegramID {
+				result.Removed++
+				continue
+			}
+			links = append(links, m)
+		}
+		d.LinkMessages = links
+
+		// Sales, freezes and the audit log stay, only without the ID
+		for _, c := range d.Clients {
+			if c.CreatedBy == telegramID {
+				c.CreatedBy = 0
+				result.Removed++
+			}
+		}
+		for _, f := range d.Freezes {
+			if f.TelegramID == telegramID {
+				f.TelegramID = 0
+				result.Removed++
+			}
+		}
+		for _, a := range d.PendingActivations {
+			if a.TelegramID == telegramID {
+				a.TelegramID = 0
+				result.Removed++
+			}
+		}
+		id := strconv.FormatInt(telegramID, 10)
+		for _, e := range d.AuditLog {
+			if e.ActorID == telegramID {
+				e.ActorID = 0
+				result.Removed++
+			}
+			// view_as and the like name the user they were about
+			if e.Target == id {
+				e.Target = ""
+				result.Removed++
+			}
+		}
+	})
+	if err != nil {
+		return result, err
+	}
+
+	batchResultsMu.Lock()
+	delete(batchResults, telegramID)
+	batchResultsMu.Unlock()
+	return result, nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Pausing a subscription while the customer is away
+type FreezeLimits struct {
+	MaxFreezes int `json:"maxFreezes"`
+	MaxDays    int `json:"maxDays"`
+}
+
+type FreezeState struct {
+	Count      int           `json:"count"`
+	FrozenAt   time.Time     `json:"frozenAt,omitempty"`
+	Remaining  time.Duration `json:"remaining,omitempty"`
+	TelegramID int64         `json:"telegramId,omitempty"`
+}
+
+func (f *FreezeState) frozen() bool {
+	return f != nil && !f.FrozenAt.IsZero()
+}
+
+var defaultFreezeLimits = FreezeLimits{MaxFreezes: 2, MaxDays: 30}
+
+// freezeLimitsFor uses the limits of the plan the client was sold with, then the global ones
+func freezeLimitsFor(uuid string) FreezeLimits {
+	var planName string
+	store.view(func(d *storeData) {
+		if c, ok := d.Clients[uuid]; ok {
+			planName = c.Plan
+		}
+	})
+
+	for _, p := range cfg.Plans {
+		if p.Name == planName && p.Freeze != nil {
+			return *p.Freeze
+		}
+	}
+	if cfg.Freeze != nil {
+		return *cfg.Freeze
+	}
+	return defaultFreezeLimits
+}
+
+func freezeStateFor(uuid string) FreezeState {
+	var state FreezeState
+	store.view(func(d *storeData) {
+		if f, ok := d.Freezes[uuid]; ok {
+			state = *f
+		}
+	})
+	return state
+}
+
+func freezeUser(ctx context.Context, user *RemnawaveUser, telegramID int64) error {
+	limits := freezeLimitsFor(user.UUID)
+	state := freezeStateFor(user.UUID)
+
+	if state.frozen() {
+		return fmt.Errorf("подписка уже заморожена")
+	}
+	if user.Status != "ACTIVE" {
+		return fmt.Errorf("заморозить можно только активную подписку")
+	}
+	if state.Count >= limits.MaxFreezes {
+		return fmt.Errorf("лимит заморозок исчерпан (%d)", limits.MaxFreezes)
+	}
+
+	remaining := time.Until(user.ExpireAt)
+	if remaining <= 0 {
+		return fmt.Errorf("подписка уже истекла")
+	}
+
+	// The record goes first: without it a disabled user could never be resumed. If the panel
+	// fails, the previous state is put back; a crash in between only makes auto-resume
+	// re-enable a user that was never disabled.
+	err := store.update(func(d *storeData) {
+		d.Freezes[user.UUID] = &FreezeState{
+			Count:      state.Count + 1,
+			FrozenAt:   time.Now(),
+			Remaining:  remaining,
+			TelegramID: telegramID,
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := setUserEnabled(ctx, user.UUID, false); err != nil {
+		if restoreErr := store.update(func(d *storeData) { d.Freezes[user.UUID] = &state }); restoreErr != nil {
+			log.Printf("Failed to restore freeze state of %s: %v", user.Username, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// resumeUser re-enables a frozen user, shifting expiry by the time spent frozen
+func resumeUser(ctx context.Context, uuid string) (time.Time, error) {
+	state := freezeStateFor(uuid)
+	if !state.frozen() {
+		return time.Time{}, fmt.Errorf("подписка не заморожена")
+	}
+
+	expireAt := time.Now().Add(state.Remaining)
+	if _, err := updateRemnawaveUser(ctx, UpdateUserRequest{UUID: uuid, ExpireAt: expireAt.UTC().Format(time.RFC3339)}); err != nil {
+		return time.Time{}, err
+	}
+	if err := setUserEnabled(ctx, uuid, true); err != nil {
+		return time.Time{}, err
+	}
+
+	err := store.update(func(d *storeData) {
+		d.Freezes[uuid] = &FreezeState{Count: state.Count}
+	})
+	return expireAt, err
+}
+
+func handleFreeze(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	user, err := getUserByTelegramID(ctx, userID)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "📋 У вас пока нет подписок."))
+		return
+	}
+
+	// The lease holds the subscription as shown here until the user confirms or goes back
+	if err := beginEdit(user, userID); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+
+	limits := freezeLimitsFor(user.UUID)
+	state := freezeStateFor(user.UUID)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❄️ Заморозить", "freeze_confirm"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "freeze_cancel"),
+		),
+	)
+
+	text := fmt.Sprintf(
+		"❄️ *Заморозка подписки*\n\n"+
+			"Подписка будет отключена, а оставшийся срок сохранится и добавится после возобновления.\n\n"+
+			"Осталось заморозок: *%d*\n"+
+			"Максимальный срок: *%d дней*, затем подписка возобновится автоматически.",
+		max(limits.MaxFreezes-state.Count, 0),
+		limits.MaxDays,
+	)
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+func handleFreezeConfirm(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	user, err := getUserByTelegramID(ctx, userID)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "📋 У вас пока нет подписок."))
+		return
+	}
+
+	if err := finishEdit(ctx, user, userID, func() error { return freezeUser(ctx, user, userID) }); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+
+	log.Printf("User %d froze subscription %s", userID, user.Username)
+	handleMySubs(ctx, bot, chatID, userID)
+}
+
+func handleFreezeCancel(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	if user, err := getUserByTelegramID(ctx, userID); err == nil {
+		endEdit(user.UUID, userID)
+	}
+	handleMySubs(ctx, bot, chatID, userID)
+}
+
+// handleResume is a single tap on the subscription card, resumeUser checks it's still frozen
+func handleResume(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	user, err := getUserByTelegramID(ctx, userID)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "📋 У вас пока нет подписок."))
+		return
+	}
+
+	err = editClient(user, userID, func() error {
+		_, err := resumeUser(ctx, user.UUID)
+		return err
+	})
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ "+err.Error()))
+		return
+	}
+
+	log.Printf("User %d resumed subscription %s", userID, user.Username)
+	handleMySubs(ctx, bot, chatID, userID)
+}
+
+// runFreezeExpiry resumes subscriptions frozen for longer than their plan allows
+func runFreezeExpiry(ctx context.Context, bot *tgbotapi.BotAPI, now time.Time) {
+	type frozenUser struct {
+		uuid  string
+		state FreezeState
+	}
+
+	var frozen []frozenUser
+	store.view(func(d *storeData) {
+		for uuid, f := range d.Freezes {
+			if f.frozen() {
+				frozen = append(frozen, frozenUser{uuid, *f})
+			}
+		}
+	})
+
+	for _, f := range frozen {
+		limits := freezeLimitsFor(f.uuid)
+		if now.Before(f.state.FrozenAt.AddDate(0, 0, limits.MaxDays)) {
+			continue
+		}
+		// Someone is editing the client right now, retry on the next tick
+		if clientLocked(f.uuid) {
+			continue
+		}
+
+		expireAt, err := resumeUser(ctx, f.uuid)
+		if err != nil {
+			log.Printf("Failed to auto-resume %s: %v", f.uuid, err)
+			continue
+		}
+		if f.state.TelegramID != 0 {
+			notifyUser(ctx, bot, f.state.TelegramID, notifySubscription, fmt.Sprintf(
+				"▶️ Заморозка закончилась, подписка снова активна до *%s*.",
+				expireAt.Format("02.01.2006"),
+			))
+		}
+	}
+}
+
+func runFreezeCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message, args *CommandArgs) error {
+	user, err := getUserByUsername(ctx, args.Positional[0])
+	if err != nil {
+		return err
+	}
+
+	var telegramID int64
+	if user.TelegramID != nil {
+		telegramID = *user.TelegramID
+	}
+	text := fmt.Sprintf("❄️ Заморозить `%s`?\n📅 Истекает: *%s*", user.Username, user.ExpireAt.Format("02.01.2006"))
+	return confirmEdit(ctx, bot, msg.Chat.ID, msg.From.ID, user, text, func(ctx context.Context) (string, error) {
+		if err := freezeUser(ctx, user, telegramID); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("❄️ `%s` заморожен", user.Username), nil
+	})
+}
+
+func runResumeCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message, args *CommandArgs) error {
+	user, err := getUserByUsername(ctx, args.Positional[0])
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("▶️ Возобновить `%s`?", user.Username)
+	return confirmEdit(ctx, bot, msg.Chat.ID, msg.From.ID, user, text, func(ctx context.Context) (string, error) {
+		expireAt, err := resumeUser(ctx, user.UUID)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("▶️ `%s` возобновлён\n📅 Истекает: *%s*", user.Username, expireAt.Format("02.01.2006")), nil
+	})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Group support: besides private chats the bot works in one admin group, optionally a
+// forum whose topics receive the output of specific features. Other groups are ignored.
+type AdminGroupConfig struct {
+	ChatID     int64          `json:"chatId"`
+	Topics     map[string]int `json:"topics"`     // command name (nodes, report, ...) to forum topic ID
+	AllowLinks bool           `json:"allowLinks"` // subscription links are hidden in groups otherwise
+}
+
+func isAdminGroup(chatID int64) bool {
+	return cfg.AdminGroup != nil && cfg.AdminGroup.ChatID == chatID
+}
+
+// chatAllowed tells whether updates from the chat are handled at all
+func chatAllowed(chat *tgbotapi.Chat) bool {
+	return chat.IsPrivate() || isAdminGroup(chat.ID)
+}
+
+// linksAllowed tells whether subscription links may be sent to the chat as is.
+// Private chat IDs are the positive user IDs, groups and channels are negative.
+func linksAllowed(chatID int64) bool {
+	return chatID > 0 || (isAdminGroup(chatID) && cfg.AdminGroup.AllowLinks)
+}
+
+// redactLinks replaces subscription links in a message text
+func redactLinks(text string) string {
+	re := regexp.MustCompile(regexp.QuoteMeta(subscriptionLink("")) + "[^\\s`]+")
+	return re.ReplaceAllString(text, "🔒 скрыта")
+}
+
+// addressedToBot tells whether a group message is meant for the bot: a mention or a reply to it
+func addressedToBot(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) bool {
+	if msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil && msg.ReplyToMessage.From.ID == bot.Self.ID {
+		return true
+	}
+	return strings.Contains(strings.ToLower(msg.Text), "@"+strings.ToLower(bot.Self.UserName))
+}
+
+// commandForBot filters out commands addressed to other bots, like /start@otherbot
+func commandForBot(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) bool {
+	_, at, ok := strings.Cut(msg.CommandWithAt(), "@")
+	return !ok || strings.EqualFold(at, bot.Self.UserName)
+}
+
+// Forum topics. The Telegram library predates them, so the topic of incoming updates is
+// read from the raw JSON and outgoing messages get message_thread_id from the context.
+
+type threadKey struct{}
+
+type threadTarget struct {
+	chatID   int64
+	threadID int
+}
+
+// withThread makes messages sent to chatID within ctx go to the given forum topic
+func withThread(ctx context.Context, chatID int64, threadID int) context.Context {
+	if threadID == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, threadKey{}, threadTarget{chatID: chatID, threadID: threadID})
+}
+
+// withFeatureTopic routes the output of a feature in the admin group to its configured topic
+func withFeatureTopic(ctx context.Context, chatID int64, feature string) context.Context {
+	if !isAdminGroup(chatID) {
+		return ctx
+	}
+	return withThread(ctx, chatID, cfg.AdminGroup.Topics[feature])
+}
+
+type topicFields struct {
+	ThreadID int  `json:"message_thread_id"`
+	IsTopic  bool `json:"is_topic_message"`
+}
+
+func (t *topicFields) topic() int {
+	if t == nil || !t.IsTopic {
+		return 0
+	}
+	return t.ThreadID
+}
+
+type TopicUpdate struct {
+	tgbotapi.Update
+	ThreadID int // forum topic of the message or the pressed button's message
+}
+
+// getUpdates polls Telegram like the library's GetUpdates and adds the forum topics
+func getUpdates(bot *tgbotapi.BotAPI, config tgbotapi.UpdateConfig) ([]TopicUpdate, error) {
+	resp, err := bot.Request(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []tgbotapi.Update
+	if err := json.Unmarshal(resp.Result, &updates); err != nil {
+		return nil, err
+	}
+	var topics []struct {
+		Message       *topicFields `json:"message"`
+		CallbackQuery *struct {
+			Message *topicFields `json:"message"`
+		} `json:"callback_query"`
+	}
+	if err := json.Unmarshal(resp.Result, &topics); err != nil {
+		return nil, err
+	}
+
+	result := make([]TopicUpdate, len(updates))
+	for i := range updates {
+		result[i].Update = updates[i]
+		if cb := topics[i].CallbackQuery; cb != nil {
+			result[i].ThreadID = cb.Message.topic()
+		} else {
+			result[i].ThreadID = topics[i].Message.topic()
+		}
+	}
+	return result, nil
+}
+
+// addThread adds message_thread_id to send* requests for the chat of the topic in ctx.
+// It goes into the URL query, which Telegram merges with the body, so that
+// multipart uploads don't have to be rebuilt.
+func addThread(ctx context.Context, req *http.Request, method string) error {
+	target, ok := ctx.Value(threadKey{}).(threadTarget)
+	if !ok || !strings.HasPrefix(method, "send") || req.Body == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	if requestChatID(req.Header.Get("Content-Type"), body) != strconv.FormatInt(target.chatID, 10) {
+		return nil
+	}
+	u := *req.URL
+	q := u.Query()
+	q.Set("message_thread_id", strconv.Itoa(target.threadID))
+	u.RawQuery = q.Encode()
+	req.URL = &u
+	return nil
+}
+
+func requestChatID(contentType string, body []byte) string {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, _ := url.ParseQuery(string(body))
+		return values.Get("chat_id")
+	case "multipart/form-data":
+		r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := r.NextPart()
+			if err != nil {
+				return ""
+			}
+			if part.FormName() == "chat_id" {
+				value, _ := io.ReadAll(part)
+				return string(value)
+			}
+		}
+	}
+	return ""
+}
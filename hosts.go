@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Hosts are the locations clients see in their subscription; disabling one hides it everywhere
+const maxHostRemark = 40
+
+func findHost(ctx context.Context, uuid string) (*Host, error) {
+	hosts, err := getHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hosts {
+		if hosts[i].UUID == uuid {
+			return &hosts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("host %s not found", uuid)
+}
+
+// inboundTagsByUUID resolves host inbounds to tags, empty when inbounds are unavailable
+func inboundTagsByUUID(ctx context.Context) map[string]string {
+	tags := make(map[string]string)
+	inbounds, err := getInbounds(ctx)
+	if err != nil {
+		log.Printf("Failed to get inbounds: %v", err)
+		return tags
+	}
+	for _, inb := range inbounds {
+		tags[inb.UUID] = inb.Tag
+	}
+	return tags
+}
+
+func hostStatus(h *Host) string {
+	if h.IsDisabled {
+		return "🔴"
+	}
+	return "🟢"
+}
+
+func sendHostsMenu(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64) {
+	hosts, err := getHosts(ctx)
+	if err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка получения хостов:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+	tags := inboundTagsByUUID(ctx)
+
+	var sb strings.Builder
+	sb.WriteString("🌍 *Хосты*\n\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := range hosts {
+		h := &hosts[i]
+		fmt.Fprintf(&sb, "%s `%s` — `%s:%d`", hostStatus(h), codeText(h.Remark), h.Address, h.Port)
+		if tag := tags[h.Inbound.ConfigProfileInboundUUID]; tag != "" {
+			fmt.Fprintf(&sb, " (`%s`)", tag)
+		}
+		sb.WriteString("\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(hostStatus(h)+" "+h.Remark, "host_view_"+h.UUID),
+		))
+	}
+	if len(hosts) == 0 {
+		sb.WriteString("Хостов нет.")
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Главное меню", "main_menu"),
+	))
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bot.Send(msg)
+}
+
+func handleHostCallback(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
+	switch {
+	case data == "hosts":
+		sendHostsMenu(ctx, bot, chatID)
+
+	case strings.HasPrefix(data, "host_view_"):
+		sendHostView(ctx, bot, chatID, strings.TrimPrefix(data, "host_view_"))
+
+	case strings.HasPrefix(data, "host_toggle_"):
+		handleHostToggle(ctx, bot, chatID, userID, strings.TrimPrefix(data, "host_toggle_"))
+
+	case strings.HasPrefix(data, "host_remark_"):
+		uuid := strings.TrimPrefix(data, "host_remark_")
+		startFlow(ctx, bot, chatID, userID, "host_remark", func(s *WizardSession) { s.Values["host"] = uuid })
+	}
+}
+
+func sendHostView(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, uuid string) {
+	host, err := findHost(ctx, uuid)
+	if err != nil {
+		log.Printf("Failed to get host %s: %v", uuid, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Хост не найден."))
+		return
+	}
+
+	state, toggle := "включён", "🔴 Выключить"
+	if host.IsDisabled {
+		state, toggle = "выключен", "🟢 Включить"
+	}
+	inbound := hostInboundTag(ctx, host)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(toggle, "host_toggle_"+host.UUID),
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Название", "host_remark_"+host.UUID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "hosts"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"🌍 *Хост* `%s`\n\n"+
+			"%s Состояние: *%s*\n"+
+			"📍 Адрес: `%s:%d`\n"+
+			"🔌 Инбаунд: `%s`",
+		codeText(host.Remark), hostStatus(host), state, host.Address, host.Port, inbound,
+	))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+func hostInboundTag(ctx context.Context, host *Host) string {
+	if tag := inboundTagsByUUID(ctx)[host.Inbound.ConfigProfileInboundUUID]; tag != "" {
+		return tag
+	}
+	return "—"
+}
+
+func handleHostToggle(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, uuid string) {
+	host, err := findHost(ctx, uuid)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Хост не найден."))
+		return
+	}
+
+	disabled := !host.IsDisabled
+	if err := updateHost(ctx, UpdateHostRequest{UUID: uuid, IsDisabled: &disabled}); err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка изменения хоста:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+
+	action := "host_enable"
+	if disabled {
+		action = "host_disable"
+	}
+	recordAudit(userID, action, host.Remark, fmt.Sprintf("%s:%d", host.Address, host.Port))
+	log.Printf("User %d: %s %s", userID, action, host.Remark)
+
+	sendHostView(ctx, bot, chatID, uuid)
+}
+
+func applyHostRemark(s *WizardSession, in WizardInput) error {
+	remark := strings.TrimSpace(in.Value)
+	if remark == "" || utf8.RuneCountInString(remark) > maxHostRemark {
+		return fmt.Errorf("название должно быть от 1 до %d символов", maxHostRemark)
+	}
+	s.Values["remark"] = remark
+	return nil
+}
+
+func finishHostRemark(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+	uuid := s.Values["host"]
+	host, err := findHost(ctx, uuid)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(s.ChatID, "❌ Хост не найден."))
+		return
+	}
+
+	if err := updateHost(ctx, UpdateHostRequest{UUID: uuid, Remark: s.Values["remark"]}); err != nil {
+		errMsg := tgbotapi.NewMessage(s.ChatID, fmt.Sprintf("❌ Ошибка изменения хоста:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+	recordAudit(s.UserID, "host_remark", host.Remark, "→ "+s.Values["remark"])
+
+	sendHostView(ctx, bot, s.ChatID, uuid)
+}
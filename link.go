@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Linking panel users created before the bot to Telegram accounts
+type LinkMatch struct {
+	UserUUID   string
+	Username   string
+	TelegramID int64
+	Reason     string
+	Version    string // clientVersion of the panel user when the match was found
+}
+
+var (
+	linkPreviews   = make(map[int64][]LinkMatch)
+	linkPreviewsMu sync.Mutex
+)
+
+const maxLinkCSVSize = 1 << 20
+
+var linkCSVClient = &http.Client{Timeout: 30 * time.Second}
+
+func sendLinkMenu(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔍 Найти совпадения", "link_scan"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Загрузить CSV", "link_csv"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Главное меню", "main_menu"),
+		),
+	)
+
+	text := "🔗 *Привязка пользователей к Telegram*\n\n" +
+		"Поиск сопоставляет пользователей панели без `telegramId` с пользователями бота " +
+		"по имени пользователя и описанию.\n\n" +
+		"CSV: строки вида `username,telegram_id`."
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+func handleLinkCallback(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
+	switch data {
+	case "link_scan":
+		handleLinkScan(ctx, bot, chatID, userID)
+
+	case "link_csv":
+		startFlow(ctx, bot, chatID, userID, "link_csv", nil)
+
+	case "link_apply":
+		handleLinkApply(ctx, bot, chatID, userID)
+
+	case "link_cancel":
+		releaseLinkPreview(userID)
+		sendMainMenu(ctx, bot, chatID)
+	}
+}
+
+// unlinkedUsers returns panel users that have no Telegram account attached
+func unlinkedUsers(ctx context.Context) ([]RemnawaveUser, error) {
+	users, err := getAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []RemnawaveUser
+	for _, u := range users {
+		if u.TelegramID == nil || *u.TelegramID == 0 {
+			result = append(result, u)
+		}
+	}
+	return result, nil
+}
+
+func handleLinkScan(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	bot.Send(tgbotapi.NewMessage(chatID, "⏳ Ищу совпадения..."))
+
+	users, err := unlinkedUsers(ctx)
+	if err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка получения пользователей:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+
+	byUsername := make(map[string]int64)
+	byID := make(map[int64]bool)
+	store.view(func(d *storeData) {
+		for id, u := range d.BotUsers {
+			byID[id] = true
+			if u.Username != "" {
+				byUsername[strings.ToLower(u.Username)] = id
+			}
+		}
+	})
+
+	mentionRe := regexp.MustCompile(`@([A-Za-z0-9_]{5,32})`)
+	idRe := regexp.MustCompile(`\b\d{5,15}\b`)
+
+	var matches []LinkMatch
+	for _, u := range users {
+		if id, ok := byUsername[strings.ToLower(u.Username)]; ok {
+			matches = append(matches, LinkMatch{UserUUID: u.UUID, Username: u.Username, TelegramID: id, Reason: "имя", Version: clientVersion(&u)})
+			continue
+		}
+
+		var found int64
+		for _, m := range mentionRe.FindAllStringSubmatch(u.Description, -1) {
+			if id, ok := byUsername[strings.ToLower(m[1])]; ok {
+				found = id
+				break
+			}
+		}
+		if found == 0 {
+			for _, m := range idRe.FindAllString(u.Description, -1) {
+				if id, err := strconv.ParseInt(m, 10, 64); err == nil && byID[id] {
+					found = id
+					break
+				}
+			}
+		}
+		if found != 0 {
+			matches = append(matches, LinkMatch{UserUUID: u.UUID, Username: u.Username, TelegramID: found, Reason: "описание", Version: clientVersion(&u)})
+		}
+	}
+
+	sendLinkPreview(ctx, bot, chatID, userID, matches, len(users))
+}
+
+func handleLinkCSV(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, doc *tgbotapi.Document) {
+	if doc.FileSize > maxLinkCSVSize {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Файл слишком большой, максимум %d КБ.", maxLinkCSVSize>>10)))
+		return
+	}
+
+	fileURL, err := bot.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		log.Printf("Failed to get CSV file URL: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось получить файл."))
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		log.Printf("Failed to download CSV file: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось скачать файл."))
+		return
+	}
+	resp, err := linkCSVClient.Do(req)
+	if err != nil {
+		// The error carries the file URL with the bot token, only the cause is logged
+		log.Printf("Failed to download CSV file: %v", errors.Unwrap(err))
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось скачать файл."))
+		return
+	}
+	defer resp.Body.Close()
+
+	mapping, err := parseLinkCSV(io.LimitReader(resp.Body, maxLinkCSVSize))
+	if err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка разбора CSV:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+
+	users, err := unlinkedUsers(ctx)
+	if err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка получения пользователей:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+
+	var matches []LinkMatch
+	for _, u := range users {
+		if id, ok := mapping[strings.ToLower(u.Username)]; ok {
+			matches = append(matches, LinkMatch{UserUUID: u.UUID, Username: u.Username, TelegramID: id, Reason: "CSV", Version: clientVersion(&u)})
+		}
+	}
+
+	sendLinkPreview(ctx, bot, chatID, userID, matches, len(users))
+}
+
+// parseLinkCSV reads "username,telegram_id" rows, skipping a header and malformed lines
+func parseLinkCSV(r io.Reader) (map[string]int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	if firstLine, _, _ := strings.Cut(string(data), "\n"); strings.Contains(firstLine, ";") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := make(map[string]int64)
+	for _, rec := range records {
+		if len(rec) < 2 {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(rec[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		mapping[strings.ToLower(strings.TrimSpace(rec[0]))] = id
+	}
+
+	if len(mapping) == 0 {
+		return nil, fmt.Errorf("no valid rows found")
+	}
+	return mapping, nil
+}
+
+// releaseLinkPreview drops the admin's pending preview together with its leases
+func releaseLinkPreview(userID int64) []LinkMatch {
+	linkPreviewsMu.Lock()
+	matches := linkPreviews[userID]
+	delete(linkPreviews, userID)
+	linkPreviewsMu.Unlock()
+
+	for _, m := range matches {
+		endEdit(m.UserUUID, userID)
+	}
+	return matches
+}
+
+func sendLinkPreview(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, matches []LinkMatch, unlinked int) {
+	releaseLinkPreview(userID)
+
+	// The preview holds the matched users as shown until it is applied or cancelled,
+	// users someone else is editing are left out
+	var leased []LinkMatch
+	busy := 0
+	for _, m := range matches {
+		if _, ok := lockClient(m.UserUUID, userID, m.Version); !ok {
+			busy++
+			continue
+		}
+		leased = append(leased, m)
+	}
+	matches = leased
+
+	linkPreviewsMu.Lock()
+	linkPreviews[userID] = matches
+	linkPreviewsMu.Unlock()
+
+	if len(matches) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🔍 Совпадений не найдено. Пользователей без Telegram: %d.", unlinked)))
+		return
+	}
+
+	const maxLines = 30
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔍 *Найдено совпадений: %d* (без Telegram: %d)\n", len(matches), unlinked)
+	if busy > 0 {
+		fmt.Fprintf(&sb, "⏸ Пропущено, их сейчас редактируют: %d\n", busy)
+	}
+	sb.WriteString("\n")
+	for i, m := range matches {
+		if i == maxLines {
+			fmt.Fprintf(&sb, "...и ещё %d\n", len(matches)-maxLines)
+			break
+		}
+		fmt.Fprintf(&sb, "`%s` → `%d` (%s)\n", m.Username, m.TelegramID, m.Reason)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ Привязать (%d)", len(matches)), "link_apply"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "link_cancel"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+func handleLinkApply(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	linkPreviewsMu.Lock()
+	matches := linkPreviews[userID]
+	delete(linkPreviews, userID)
+	linkPreviewsMu.Unlock()
+
+	if len(matches) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Нет совпадений для привязки. Запустите поиск заново."))
+		return
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, "⏳ Привязываю..."))
+
+	linked, failed := 0, 0
+	for _, m := range matches {
+		tgID := m.TelegramID
+		err := finishEdit(ctx, &RemnawaveUser{UUID: m.UserUUID, Username: m.Username}, userID, func() error {
+			_, err := updateRemnawaveUser(ctx, UpdateUserRequest{UUID: m.UserUUID, TelegramID: &tgID})
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to link %s to %d: %v", m.Username, m.TelegramID, err)
+			failed++
+			continue
+		}
+		linked++
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Главное меню", "main_menu"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Привязано: %d\n❌ Ошибок: %d", linked, failed))
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+// shortUUIDFromLink takes the last path segment of a subscription link
+func shortUUIDFromLink(link string) string {
+	link = strings.TrimRight(strings.TrimSpace(link), "/")
+	return link[strings.LastIndex(link, "/")+1:]
+}
+
+// finishClaimLink attaches the subscription behind a link to the sender, if nobody owns it yet
+func finishClaimLink(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, shortUUID string) {
+	user, err := getUserByShortUUID(ctx, shortUUID)
+	if err != nil {
+		log.Printf("Claim lookup for %q failed: %v", shortUUID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Подписка по этой ссылке не найдена."))
+		return
+	}
+
+	if user.TelegramID != nil && *user.TelegramID != 0 && *user.TelegramID != userID {
+		bot.Send(tgbotapi.NewMessage(chatID, "⛔ Эта подписка уже привязана к другому аккаунту."))
+		return
+	}
+
+	tgID := userID
+	err = editClient(user, userID, func() error {
+		_, err := updateRemnawaveUser(ctx, UpdateUserRequest{UUID: user.UUID, TelegramID: &tgID})
+		return err
+	})
+	if err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка привязки:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+
+	log.Printf("User %d claimed subscription %s", userID, user.Username)
+	handleMySubs(ctx, bot, chatID, userID)
+}
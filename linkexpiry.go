@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Subscription links don't stay in chat history forever: messages carrying them are
+// edited out or deleted after a while and can be reissued with a button
+type LinkExpiryConfig struct {
+	Minutes int    `json:"minutes"`
+	Mode    string `json:"mode"` // edit (default) replaces the text, delete removes the message
+}
+
+type LinkMessage struct {
+	ChatID    int64     `json:"chatId"`
+	MessageID int       `json:"messageId"`
+	UUID      string    `json:"uuid,omitempty"` // empty for messages with several links
+	Username  string    `json:"username,omitempty"`
+	Document  bool      `json:"document,omitempty"` // can't be edited into text, only deleted
+	HideAt    time.Time `json:"hideAt"`
+}
+
+// sendLinkMessage sends a message containing a subscription link and schedules hiding it.
+// In groups the links are cut out unless allowed, the button reissues them privately.
+func sendLinkMessage(bot *tgbotapi.BotAPI, msg tgbotapi.MessageConfig, uuid, username string) error {
+	if !linksAllowed(msg.ChatID) {
+		msg.Text = redactLinks(msg.Text)
+		if keyboard, ok := msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup); ok && uuid != "" {
+			keyboard.InlineKeyboard = append(showLinkButton(uuid).InlineKeyboard, keyboard.InlineKeyboard...)
+			msg.ReplyMarkup = keyboard
+		} else if uuid != "" {
+			msg.ReplyMarkup = showLinkButton(uuid)
+		}
+		_, err := bot.Send(msg)
+		return err
+	}
+
+	sent, err := bot.Send(msg)
+	if err != nil {
+		return err
+	}
+	trackLinkMessage(&LinkMessage{ChatID: sent.Chat.ID, MessageID: sent.MessageID, UUID: uuid, Username: username})
+	return nil
+}
+
+// sendLinkDocument sends a file with subscription links, it is deleted when the links expire
+func sendLinkDocument(bot *tgbotapi.BotAPI, doc tgbotapi.DocumentConfig) error {
+	sent, err := bot.Send(doc)
+	if err != nil {
+		return err
+	}
+	trackLinkMessage(&LinkMessage{ChatID: sent.Chat.ID, MessageID: sent.MessageID, Document: true})
+	return nil
+}
+
+func trackLinkMessage(m *LinkMessage) {
+	if cfg.LinkExpiry == nil {
+		return
+	}
+	m.HideAt = time.Now().Add(time.Duration(cfg.LinkExpiry.Minutes) * time.Minute)
+	if err := store.update(func(d *storeData) { d.LinkMessages = append(d.LinkMessages, m) }); err != nil {
+		log.Printf("Failed to schedule hiding link message: %v", err)
+	}
+}
+
+func showLinkButton(uuid string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👁 Показать ссылку", "showlink_"+uuid),
+		),
+	)
+}
+
+// A message that couldn't be hidden for a transient reason is retried after a while
+const linkHideRetry = 5 * time.Minute
+
+func hideExpiredLinks(ctx context.Context, bot *tgbotapi.BotAPI, now time.Time) {
+	var due []LinkMessage
+	store.view(func(d *storeData) {
+		for _, m := range d.LinkMessages {
+			if !m.HideAt.After(now) {
+				due = append(due, *m)
+			}
+		}
+	})
+	if len(due) == 0 {
+		return
+	}
+
+	// Entries scheduled before the option was switched off are still hidden
+	mode := "edit"
+	if cfg.LinkExpiry != nil && cfg.LinkExpiry.Mode != "" {
+		mode = cfg.LinkExpiry.Mode
+	}
+
+	type messageKey struct {
+		chatID    int64
+		messageID int
+	}
+	done := make(map[messageKey]bool)
+	for i := range due {
+		m := &due[i]
+		err := hideLinkMessage(bot, m, mode)
+		if err != nil && !permanentTelegramError(err) {
+			log.Printf("Failed to hide link message %d in %d, retrying later: %v", m.MessageID, m.ChatID, err)
+			continue
+		}
+		if err != nil {
+			log.Printf("Link message %d in %d can't be hidden: %v", m.MessageID, m.ChatID, err)
+		}
+		done[messageKey{m.ChatID, m.MessageID}] = true
+	}
+
+	// Entries are dropped only once handled, so a restart or a Telegram hiccup doesn't leave links visible
+	err := store.update(func(d *storeData) {
+		var rest []*LinkMessage
+		for _, m := range d.LinkMessages {
+			if done[messageKey{m.ChatID, m.MessageID}] {
+				continue
+			}
+			if !m.HideAt.After(now) {
+				m.HideAt = now.Add(linkHideRetry)
+			}
+			rest = append(rest, m)
+		}
+		d.LinkMessages = rest
+	})
+	if err != nil {
+		log.Printf("Failed to save link messages: %v", err)
+	}
+}
+
+// permanentTelegramError tells errors Telegram will keep returning for the same request,
+// such as a message already gone or a chat the bot was removed from, from transient ones
+func permanentTelegramError(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == 400 || apiErr.Code == 403)
+}
+
+func hideLinkMessage(bot *tgbotapi.BotAPI, m *LinkMessage, mode string) error {
+	text := "🔒 Ссылки скрыты. Выгрузите их заново, если они ещё нужны."
+	if m.UUID != "" {
+		text = fmt.Sprintf("🔒 Ссылка на подписку `%s` скрыта.", m.Username)
+	}
+
+	if m.Document {
+		_, err := bot.Request(tgbotapi.NewDeleteMessage(m.ChatID, m.MessageID))
+		return err
+	}
+
+	if mode == "delete" {
+		// Telegram only lets bots delete messages younger than 48 hours, older ones are edited
+		_, err := bot.Request(tgbotapi.NewDeleteMessage(m.ChatID, m.MessageID))
+		if err == nil {
+			if m.UUID != "" {
+				msg := tgbotapi.NewMessage(m.ChatID, text)
+				msg.ParseMode = "Markdown"
+				msg.ReplyMarkup = showLinkButton(m.UUID)
+				bot.Send(msg)
+			}
+			return nil
+		}
+		log.Printf("Failed to delete link message %d in %d, editing instead: %v", m.MessageID, m.ChatID, err)
+	}
+
+	edit := tgbotapi.NewEditMessageText(m.ChatID, m.MessageID, text)
+	edit.ParseMode = "Markdown"
+	if m.UUID != "" {
+		keyboard := showLinkButton(m.UUID)
+		edit.ReplyMarkup = &keyboard
+	}
+	_, err := bot.Send(edit)
+	return err
+}
+
+// handleShowLink reissues a hidden link to an admin, the subscription owner or their family owner
+func handleShowLink(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, uuid string) {
+	user, err := getUserByUUID(ctx, uuid)
+	if err != nil {
+		log.Printf("Failed to get user %s: %v", uuid, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Подписка не найдена."))
+		return
+	}
+
+	allowed := isAdmin(userID) || (user.TelegramID != nil && *user.TelegramID == userID)
+	if family := familyOf(userID); family != nil && family.member(uuid) != nil {
+		allowed = true
+	}
+	if !allowed {
+		bot.Send(tgbotapi.NewMessage(chatID, "⛔ У вас нет доступа."))
+		return
+	}
+
+	// Pressed in a group where links are hidden: the link goes to the private chat
+	target := chatID
+	if !linksAllowed(chatID) {
+		target = userID
+	}
+	msg := tgbotapi.NewMessage(target, fmt.Sprintf("🔗 *Ссылка на подписку* `%s`:\n`%s`", user.Username, subscriptionLink(user.ShortUUID)))
+	msg.ParseMode = "Markdown"
+	if err := sendLinkMessage(bot, msg, user.UUID, user.Username); err != nil && target != chatID {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Не удалось отправить ссылку в личные сообщения. Начните диалог с ботом и попробуйте снова."))
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Server list preview decoded from subscription contents
+type Location struct {
+	Remark   string
+	Protocol string
+	Flag     string
+}
+
+var subscriptionClient = &http.Client{Timeout: 15 * time.Second}
+
+// Telegram's limit is 4096 characters; the length in bytes is never below that count
+const maxLocationsMessageLen = 4000
+
+// fetchSubscription downloads what a VPN client would get; the link identifies the
+// subscription, so it stays out of the span
+func fetchSubscription(ctx context.Context, link string) (body []byte, err error) {
+	ctx, span := tracer.Start(ctx, "subscription fetch", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() {
+		if err != nil {
+			failSpan(span, err)
+		}
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := subscriptionClient.Do(req)
+	if err != nil {
+		// The full error carries the link
+		if urlErr := (*url.Error)(nil); errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("subscription error %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// parseSubscription detects sing-box/Xray JSON, Clash YAML and base64 or plain URI lists
+func parseSubscription(data []byte) []Location {
+	text := strings.TrimSpace(string(data))
+
+	if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		return parseJSONSubscription([]byte(text))
+	}
+	if strings.Contains(text, "proxies:") {
+		return parseClashSubscription(text)
+	}
+
+	if !strings.Contains(text, "://") {
+		compact := strings.Join(strings.Fields(text), "")
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if decoded, err := enc.DecodeString(compact); err == nil {
+				text = string(decoded)
+				break
+			}
+		}
+	}
+	return parseURIList(text)
+}
+
+func parseURIList(text string) []Location {
+	var locations []Location
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		scheme, rest, ok := strings.Cut(line, "://")
+		if !ok {
+			continue
+		}
+		scheme = strings.ToLower(scheme)
+
+		remark := ""
+		if scheme == "vmess" {
+			// vmess links carry a base64 JSON with the remark in "ps"
+			var v struct {
+				PS string `json:"ps"`
+			}
+			for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding} {
+				if raw, err := enc.DecodeString(rest); err == nil && json.Unmarshal(raw, &v) == nil {
+					remark = v.PS
+					break
+				}
+			}
+		} else if _, fragment, ok := strings.Cut(rest, "#"); ok {
+			if unescaped, err := url.PathUnescape(fragment); err == nil {
+				remark = unescaped
+			} else {
+				remark = fragment
+			}
+		}
+
+		locations = append(locations, newLocation(remark, scheme))
+	}
+	return locations
+}
+
+func parseJSONSubscription(data []byte) []Location {
+	// sing-box config
+	var singbox struct {
+		Outbounds []struct {
+			Type string `json:"type"`
+			Tag  string `json:"tag"`
+		} `json:"outbounds"`
+	}
+	if err := json.Unmarshal(data, &singbox); err == nil && len(singbox.Outbounds) > 0 {
+		var locations []Location
+		for _, o := range singbox.Outbounds {
+			switch o.Type {
+			case "direct", "block", "dns", "selector", "urltest":
+				continue
+			}
+			locations = append(locations, newLocation(o.Tag, o.Type))
+		}
+		return locations
+	}
+
+	// Xray JSON: a list of full configs, one per server
+	var xray []struct {
+		Remarks   string `json:"remarks"`
+		Outbounds []struct {
+			Protocol string `json:"protocol"`
+		} `json:"outbounds"`
+	}
+	if err := json.Unmarshal(data, &xray); err == nil {
+		var locations []Location
+		for _, c := range xray {
+			protocol := ""
+			if len(c.Outbounds) > 0 {
+				protocol = c.Outbounds[0].Protocol
+			}
+			locations = append(locations, newLocation(c.Remarks, protocol))
+		}
+		return locations
+	}
+	return nil
+}
+
+// parseClashSubscription reads names and types from the proxies section without a YAML dependency
+func parseClashSubscription(text string) []Location {
+	flowName := regexp.MustCompile(`name:\s*("[^"]*"|'[^']*'|[^,}]+)`)
+	flowType := regexp.MustCompile(`type:\s*([A-Za-z0-9-]+)`)
+	unquote := func(s string) string {
+		return strings.Trim(strings.TrimSpace(s), `"'`)
+	}
+
+	var locations []Location
+	inProxies := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") && trimmed != "" {
+			inProxies = trimmed == "proxies:"
+			continue
+		}
+		if !inProxies {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "- {"):
+			loc := Location{}
+			if m := flowName.FindStringSubmatch(trimmed); m != nil {
+				loc.Remark = unquote(m[1])
+			}
+			if m := flowType.FindStringSubmatch(trimmed); m != nil {
+				loc.Protocol = m[1]
+			}
+			locations = append(locations, newLocation(loc.Remark, loc.Protocol))
+
+		case strings.HasPrefix(trimmed, "- name:"):
+			locations = append(locations, newLocation(unquote(strings.TrimPrefix(trimmed, "- name:")), ""))
+
+		case strings.HasPrefix(trimmed, "type:") && len(locations) > 0 && locations[len(locations)-1].Protocol == "":
+			locations[len(locations)-1].Protocol = unquote(strings.TrimPrefix(trimmed, "type:"))
+		}
+	}
+	return locations
+}
+
+var countryCodeRe = regexp.MustCompile(`\b([A-Z]{2})\b`)
+
+// newLocation takes the flag from the remark, or derives it from a country code in it
+func newLocation(remark, protocol string) Location {
+	loc := Location{Remark: strings.TrimSpace(remark), Protocol: strings.ToLower(protocol)}
+
+	runes := []rune(loc.Remark)
+	if len(runes) >= 2 && isRegionalIndicator(runes[0]) && isRegionalIndicator(runes[1]) {
+		loc.Flag = string(runes[:2])
+		loc.Remark = strings.TrimSpace(string(runes[2:]))
+	} else if m := countryCodeRe.FindStringSubmatch(loc.Remark); m != nil {
+		loc.Flag = countryFlag(m[1])
+	}
+	if loc.Remark == "" {
+		loc.Remark = "Без названия"
+	}
+	return loc
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func countryFlag(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+	code = strings.ToUpper(code)
+	return string([]rune{0x1F1E6 + rune(code[0]-'A'), 0x1F1E6 + rune(code[1]-'A')})
+}
+
+func handleLocations(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	user, err := getUserByTelegramID(ctx, userID)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "📋 У вас пока нет подписок."))
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "my_subs"),
+		),
+	)
+
+	data, err := fetchSubscription(ctx, subscriptionLink(user.ShortUUID))
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ Не удалось загрузить подписку. Попробуйте позже.")
+		msg.ReplyMarkup = keyboard
+		bot.Send(msg)
+		return
+	}
+
+	locations := parseSubscription(data)
+	if len(locations) == 0 {
+		msg := tgbotapi.NewMessage(chatID, "🌍 В подписке пока нет серверов.")
+		msg.ReplyMarkup = keyboard
+		bot.Send(msg)
+		return
+	}
+
+	// A long list goes out in several messages, the last one carries the keyboard
+	var parts []string
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🌍 Локации (%d):\n\n", len(locations))
+	for _, loc := range locations {
+		flag := loc.Flag
+		if flag == "" {
+			flag = "🏳"
+		}
+		line := fmt.Sprintf("%s %s", flag, loc.Remark)
+		if loc.Protocol != "" {
+			line += " · " + loc.Protocol
+		}
+		line += "\n"
+		if sb.Len()+len(line) > maxLocationsMessageLen {
+			parts = append(parts, sb.String())
+			sb.Reset()
+		}
+		sb.WriteString(line)
+	}
+	parts = append(parts, sb.String())
+
+	// Remarks come from the panel and may contain markdown characters, so send as plain text
+	for i, part := range parts {
+		msg := tgbotapi.NewMessage(chatID, part)
+		if i == len(parts)-1 {
+			msg.ReplyMarkup = keyboard
+		}
+		bot.Send(msg)
+	}
+}
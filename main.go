@@ -2,32 +2,38 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Remnawave API types
+
 type CreateUserRequest struct {
-	Username          string   `json:"username"`
-	TrafficLimitBytes int64    `json:"trafficLimitBytes,omitempty"`
-	ExpireAt          string   `json:"expireAt,omitempty"`
-	TelegramID        int64    `json:"telegramId,omitempty"`
-	Description       string   `json:"description,omitempty"`
-	Tag               string   `json:"tag,omitempty"`
-	HwidDeviceLimit   int      `json:"hwidDeviceLimit,omitempty"`
-	ActiveUserInbounds  []InboundTag `json:"activeUserInbounds,omitempty"`
-	ActiveInternalSquads []string    `json:"activeInternalSquads,omitempty"`
+	Username             string       `json:"username"`
+	TrafficLimitBytes    int64        `json:"trafficLimitBytes,omitempty"`
+	ExpireAt             string       `json:"expireAt,omitempty"`
+	TelegramID           int64        `json:"telegramId,omitempty"`
+	Description          string       `json:"description,omitempty"`
+	Tag                  string       `json:"tag,omitempty"`
+	Status               string       `json:"status,omitempty"`
+	HwidDeviceLimit      int          `json:"hwidDeviceLimit,omitempty"`
+	ActiveUserInbounds   []InboundTag `json:"activeUserInbounds,omitempty"`
+	ActiveInternalSquads []string     `json:"activeInternalSquads,omitempty"`
 }
 
 type InboundTag struct {
@@ -35,12 +41,45 @@ type InboundTag struct {
 }
 
 type RemnawaveUser struct {
-	UUID             string `json:"uuid"`
-	Username         string `json:"username"`
-	ShortUUID        string `json:"shortUuid"`
-	SubscriptionUUID string `json:"subscriptionUuid"`
-	Status           string `json:"status"`
-	ShortURL         string `json:"subscriptionUrl"`
+	UUID                 string          `json:"uuid"`
+	Username             string          `json:"username"`
+	ShortUUID            string          `json:"shortUuid"`
+	SubscriptionUUID     string          `json:"subscriptionUuid"`
+	Status               string          `json:"status"`
+	ShortURL             string          `json:"subscriptionUrl"`
+	TelegramID           *int64          `json:"telegramId"`
+	Description          string          `json:"description"`
+	TrafficLimitBytes    int64           `json:"trafficLimitBytes"`
+	UsedTrafficBytes     int64           `json:"usedTrafficBytes"`
+	ExpireAt             time.Time       `json:"expireAt"`
+	CreatedAt            time.Time       `json:"createdAt"`
+	OnlineAt             *time.Time      `json:"onlineAt"`
+	ActiveInternalSquads []InternalSquad `json:"activeInternalSquads"`
+	ActiveUserInbounds   []Inbound       `json:"activeUserInbounds"`
+	HwidDeviceLimit      *int            `json:"hwidDeviceLimit"`
+	Tag                  *string         `json:"tag"`
+}
+
+type UsersPage struct {
+	Users []RemnawaveUser `json:"users"`
+	Total int             `json:"total"`
+}
+
+type UsersPageResponse struct {
+	Response UsersPage `json:"response"`
+}
+
+type UpdateUserRequest struct {
+	UUID                 string   `json:"uuid"`
+	TelegramID           *int64   `json:"telegramId,omitempty"`
+	ExpireAt             string   `json:"expireAt,omitempty"`
+	ActiveInternalSquads []string `json:"activeInternalSquads,omitempty"`
+}
+
+// UnlinkTelegramRequest sends an explicit null, UpdateUserRequest omits an empty ID
+type UnlinkTelegramRequest struct {
+	UUID       string `json:"uuid"`
+	TelegramID *int64 `json:"telegramId"`
 }
 
 type RemnawaveResponse struct {
@@ -57,39 +96,127 @@ type Inbound struct {
 	Type string `json:"type"`
 }
 
+type Host struct {
+	UUID       string      `json:"uuid"`
+	Remark     string      `json:"remark"`
+	Address    string      `json:"address"`
+	Port       int         `json:"port"`
+	IsDisabled bool        `json:"isDisabled"`
+	Inbound    HostInbound `json:"inbound"`
+}
+
+type HostInbound struct {
+	ConfigProfileInboundUUID string `json:"configProfileInboundUuid"`
+}
+
+type HostsResponse struct {
+	Response []Host `json:"response"`
+}
+
+type UpdateHostRequest struct {
+	UUID       string `json:"uuid"`
+	Remark     string `json:"remark,omitempty"`
+	IsDisabled *bool  `json:"isDisabled,omitempty"`
+}
+
+type SystemStats struct {
+	CPU struct {
+		Cores         int `json:"cores"`
+		PhysicalCores int `json:"physicalCores"`
+	} `json:"cpu"`
+	Memory struct {
+		Total     int64 `json:"total"`
+		Used      int64 `json:"used"`
+		Available int64 `json:"available"`
+	} `json:"memory"`
+	Uptime float64 `json:"uptime"` // seconds
+	Users  struct {
+		TotalUsers int `json:"totalUsers"`
+	} `json:"users"`
+	OnlineStats struct {
+		OnlineNow int `json:"onlineNow"`
+		LastDay   int `json:"lastDay"`
+	} `json:"onlineStats"`
+	Nodes struct {
+		TotalOnline int `json:"totalOnline"`
+	} `json:"nodes"`
+}
+
+type BandwidthPeriod struct {
+	Current    string `json:"current"`
+	Previous   string `json:"previous"`
+	Difference string `json:"difference"`
+}
+
+type BandwidthStats struct {
+	LastTwoDays   BandwidthPeriod `json:"bandwidthLastTwoDays"`
+	CalendarMonth BandwidthPeriod `json:"bandwidthCalendarMonth"`
+}
+
 type InternalSquad struct {
+	UUID     string    `json:"uuid"`
+	Name     string    `json:"name"`
+	Info     SquadInfo `json:"info"`
+	Inbounds []Inbound `json:"inbounds"`
+}
+
+type SquadInfo struct {
+	MembersCount  int `json:"membersCount"`
+	InboundsCount int `json:"inboundsCount"`
+}
+
+type InternalSquadResponse struct {
+	Response InternalSquad `json:"response"`
+}
+
+type CreateSquadRequest struct {
+	Name     string   `json:"name"`
+	Inbounds []string `json:"inbounds"`
+}
+
+type UpdateSquadRequest struct {
 	UUID string `json:"uuid"`
-	Name string `json:"name"`
+	Name string `json:"name,omitempty"`
 }
 
 type InternalSquadsResponse struct {
 	Response []InternalSquad `json:"response"`
 }
 
-// Bot state management
-type UserState struct {
-	Step       string
-	TrafficGB  int
-	DaysExpire int
-	ClientName string
+type Node struct {
+	UUID              string `json:"uuid"`
+	Name              string `json:"name"`
+	Address           string `json:"address"`
+	CountryCode       string `json:"countryCode"`
+	IsConnected       bool   `json:"isConnected"`
+	IsDisabled        bool   `json:"isDisabled"`
+	TrafficUsedBytes  int64  `json:"trafficUsedBytes"`
+	TrafficLimitBytes int64  `json:"trafficLimitBytes"`
+	XrayVersion       string `json:"xrayVersion"`
+	UsersOnline       int    `json:"usersOnline"`
 }
 
-var (
-	userStates = make(map[int64]*UserState)
-	statesMu   sync.Mutex
+type NodesResponse struct {
+	Response []Node `json:"response"`
+}
 
+var (
 	remnawaveAPI   string
 	remnawaveToken string
 	subDomain      string
 	botToken       string
+	dataFile       string
+	configFile     string
 	adminIDs       map[int64]bool
+	ownerIDs       map[int64]bool
+	supportIDs     map[int64]bool
+
+	store *Store
+	cfg   *Config
 )
 
 func init() {
 	botToken = os.Getenv("BOT_TOKEN")
-	if botToken == "" {
-		log.Fatal("BOT_TOKEN is required")
-	}
 
 	remnawaveAPI = os.Getenv("REMNAWAVE_API")
 	if remnawaveAPI == "" {
@@ -98,9 +225,6 @@ func init() {
 	remnawaveAPI = strings.TrimRight(remnawaveAPI, "/")
 
 	remnawaveToken = os.Getenv("REMNAWAVE_TOKEN")
-	if remnawaveToken == "" {
-		log.Fatal("REMNAWAVE_TOKEN is required")
-	}
 
 	subDomain = os.Getenv("SUB_DOMAIN")
 	if subDomain == "" {
@@ -108,61 +232,249 @@ func init() {
 	}
 	subDomain = strings.TrimRight(subDomain, "/")
 
-	// Parse admin IDs (comma-separated)
-	adminIDs = make(map[int64]bool)
-	if ids := os.Getenv("ADMIN_IDS"); ids != "" {
-		for _, idStr := range strings.Split(ids, ",") {
-			id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
-			if err == nil {
-				adminIDs[id] = true
-			}
+	dataFile = os.Getenv("DATA_FILE")
+	if dataFile == "" {
+		dataFile = "botik-data.json"
+	}
+
+	configFile = os.Getenv("CONFIG_FILE")
+	if configFile == "" {
+		configFile = "config.json"
+	}
+
+	adminIDs = parseIDList(os.Getenv("ADMIN_IDS"))
+	ownerIDs = parseIDList(os.Getenv("OWNER_IDS"))
+	supportIDs = parseIDList(os.Getenv("SUPPORT_IDS"))
+}
+
+// parseIDList parses a comma-separated list of Telegram IDs
+func parseIDList(ids string) map[int64]bool {
+	result := make(map[int64]bool)
+	if ids == "" {
+		return result
+	}
+	for _, idStr := range strings.Split(ids, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+		if err == nil {
+			result[id] = true
 		}
 	}
+	return result
 }
 
+// Admins and owners come from the env and the config, the latter can change on reload
 func isAdmin(userID int64) bool {
-	if len(adminIDs) == 0 {
+	if len(adminIDs) == 0 && len(cfg.Admins) == 0 {
 		return true // no restriction if no admins configured
 	}
-	return adminIDs[userID]
+	return adminIDs[userID] || slices.Contains(cfg.Admins, userID)
+}
+
+func isOwner(userID int64) bool {
+	if len(ownerIDs) == 0 && len(cfg.Owners) == 0 {
+		return isAdmin(userID) // every admin is an owner if no owners configured
+	}
+	return ownerIDs[userID] || slices.Contains(cfg.Owners, userID)
+}
+
+// isSupport allows looking at the bot through a customer's eyes, admins can do it too
+func isSupport(userID int64) bool {
+	return isAdmin(userID) || supportIDs[userID] || slices.Contains(cfg.Support, userID)
 }
 
 func main() {
+	// Offline maintenance commands don't need Telegram or the panel
+	if len(os.Args) > 1 {
+		if err := runCLI(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if botToken == "" {
+		log.Fatal("BOT_TOKEN is required")
+	}
+	if remnawaveToken == "" {
+		log.Fatal("REMNAWAVE_TOKEN is required")
+	}
+
 	bot, err := tgbotapi.NewBotAPI(botToken)
 	if err != nil {
 		log.Fatalf("Failed to create bot: %v", err)
 	}
 
+	cfg, err = loadConfig(configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	keys, err := loadStoreKeys(cfg.Encryption)
+	if err != nil {
+		log.Fatalf("Failed to load encryption keys: %v", err)
+	}
+	store, err = openStore(dataFile, keys)
+	if err != nil {
+		log.Fatalf("Failed to open data file: %v", err)
+	}
+
+	shutdownTracing, err := initTracing(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to init tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	startScheduler(bot)
+	watchConfig(bot)
+
 	log.Printf("Bot started: @%s", bot.Self.UserName)
 
-	u := tgbotapi.NewUpdate(0)
+	handled := openHandledUpdates(dataFile+".updates", bot.Self.ID)
+	u := tgbotapi.NewUpdate(handled.offset())
 	u.Timeout = 60
-	updates := bot.GetUpdatesChan(u)
 
-	for update := range updates {
-		if update.CallbackQuery != nil {
-			handleCallback(bot, update.CallbackQuery)
+	for {
+		updates, err := getUpdates(bot, u)
+		if err != nil {
+			log.Printf("Failed to get updates, retrying in 3 seconds: %v", err)
+			time.Sleep(3 * time.Second)
 			continue
 		}
-		if update.Message == nil {
-			continue
+		for _, update := range updates {
+			if handled.has(update.UpdateID) {
+				log.Printf("Skipping replayed update %d", update.UpdateID)
+			} else {
+				handleUpdate(bot, update)
+				handled.add(update.UpdateID)
+			}
+			// Confirmed to Telegram with the next poll, only once the update is done
+			u.Offset = update.UpdateID + 1
 		}
-		if update.Message.IsCommand() {
-			handleCommand(bot, update.Message)
-			continue
+	}
+}
+
+// handleUpdate processes one update under its own trace
+func handleUpdate(bot *tgbotapi.BotAPI, update TopicUpdate) {
+	ctx, span := tracer.Start(context.Background(), "update",
+		trace.WithAttributes(attribute.Int("update.id", update.UpdateID)),
+	)
+	defer span.End()
+
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	chat, from := update.FromChat(), update.SentFrom()
+	if chat == nil || from == nil {
+		return
+	}
+	// In the admin group only admins are listened to, other groups are ignored entirely
+	if !chatAllowed(chat) || (!chat.IsPrivate() && !isAdmin(from.ID)) {
+		return
+	}
+	ctx = withThread(ctx, chat.ID, update.ThreadID)
+
+	if update.CallbackQuery != nil {
+		rememberBotUser(update.CallbackQuery.From)
+		handleCallback(ctx, bot, update.CallbackQuery)
+		return
+	}
+	if update.Message == nil {
+		return
+	}
+	rememberBotUser(update.Message.From)
+	if update.Message.IsCommand() {
+		if commandForBot(bot, update.Message) {
+			handleCommand(ctx, bot, update.Message)
 		}
-		handleText(bot, update.Message)
+		return
 	}
+	handleText(ctx, bot, update.Message)
 }
 
-func handleCommand(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+func handleCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	ctx, span := tracer.Start(ctx, "command /"+msg.Command())
+	defer span.End()
+	ctx = withFeatureTopic(ctx, msg.Chat.ID, msg.Command())
+	bot = tracedBot(ctx, bot)
+
 	switch msg.Command() {
 	case "start":
-		sendMainMenu(bot, msg.Chat.ID)
+		sendMainMenu(ctx, bot, msg.Chat.ID)
+	case "link":
+		if !isOwner(msg.From.ID) {
+			bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⛔ У вас нет доступа."))
+			return
+		}
+		sendLinkMenu(ctx, bot, msg.Chat.ID)
+	case "report":
+		if !isAdmin(msg.From.ID) {
+			bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⛔ У вас нет доступа."))
+			return
+		}
+		handleReportCommand(ctx, bot, msg)
+	case "announce":
+		if !isOwner(msg.From.ID) {
+			bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⛔ У вас нет доступа."))
+			return
+		}
+		handleAnnounceCommand(ctx, bot, msg)
+	case "squads":
+		if !isAdmin(msg.From.ID) {
+			bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⛔ У вас нет доступа."))
+			return
+		}
+		sendSquadsMenu(ctx, bot, msg.Chat.ID)
+	case "hosts":
+		if !isAdmin(msg.From.ID) {
+			bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⛔ У вас нет доступа."))
+			return
+		}
+		sendHostsMenu(ctx, bot, msg.Chat.ID)
+	case "nodes":
+		if !isAdmin(msg.From.ID) {
+			bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⛔ У вас нет доступа."))
+			return
+		}
+		sendNodesMenu(ctx, bot, msg.Chat.ID, msg.From.ID)
+	case "system":
+		if !isAdmin(msg.From.ID) {
+			bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⛔ У вас нет доступа."))
+			return
+		}
+		sendSystemStats(ctx, bot, msg.Chat.ID)
+	case "viewas":
+		if !isSupport(msg.From.ID) {
+			bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⛔ У вас нет доступа."))
+			return
+		}
+		runViewAsCommand(ctx, bot, msg)
+	case "forgetme":
+		sendForgetConfirm(ctx, bot, msg.Chat.ID, msg.From.ID, true)
+	case "forget":
+		if !isOwner(msg.From.ID) {
+			bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⛔ У вас нет доступа."))
+			return
+		}
+		runForgetCommand(ctx, bot, msg)
+	case "help":
+		if !isAdmin(msg.From.ID) {
+			sendMainMenu(ctx, bot, msg.Chat.ID)
+			return
+		}
+		sendCommandHelp(ctx, bot, msg.Chat.ID)
+	default:
+		cmd, ok := textCommands[msg.Command()]
+		if !ok {
+			return
+		}
+		if !isAdmin(msg.From.ID) {
+			bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⛔ У вас нет доступа."))
+			return
+		}
+		handleTextCommand(ctx, bot, msg, cmd)
 	}
 }
 
-func sendMainMenu(bot *tgbotapi.BotAPI, chatID int64) {
+func sendMainMenu(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64) {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("➕ Создать клиента", "create_client"),
@@ -170,6 +482,9 @@ func sendMainMenu(bot *tgbotapi.BotAPI, chatID int64) {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("📋 Мои подписки", "my_subs"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚙️ Настройки", "settings"),
+		),
 	)
 
 	text := "🔐 *Панель управления VPN*\n\nВыберите действие:"
@@ -179,175 +494,332 @@ func sendMainMenu(bot *tgbotapi.BotAPI, chatID int64) {
 	bot.Send(msg)
 }
 
-func handleCallback(bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+// Callbacks available to customers without admin rights
+var customerCallbacks = map[string]bool{
+	"main_menu":  true,
+	"my_subs":    true,
+	"claim_link": true,
+	"locations":  true,
+
+	"freeze":         true,
+	"freeze_confirm": true,
+	"freeze_cancel":  true,
+	"resume":         true,
+
+	"settings":      true,
+	"pref_expiry":   true,
+	"pref_traffic":  true,
+	"pref_announce": true,
+	"pref_quiet":    true,
+	"pref_tz":       true,
+
+	"viewas_ro": true,
+}
+
+// Prefixes of customer callbacks carrying an ID; handlers check ownership themselves
+var customerCallbackPrefixes = []string{"family", "showlink_", "forget_", "wz:"}
+
+func isCustomerCallback(data string) bool {
+	if customerCallbacks[data] {
+		return true
+	}
+	for _, prefix := range customerCallbackPrefixes {
+		if strings.HasPrefix(data, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func handleCallback(ctx context.Context, bot *tgbotapi.BotAPI, cb *tgbotapi.CallbackQuery) {
+	// Span names use the callback family only, the full data may carry IDs
+	family, _, _ := strings.Cut(strings.ReplaceAll(cb.Data, ":", "_"), "_")
+	ctx, span := tracer.Start(ctx, "callback "+family)
+	defer span.End()
+	bot = tracedBot(ctx, bot)
+
 	bot.Send(tgbotapi.NewCallback(cb.ID, ""))
 
 	userID := cb.From.ID
 	chatID := cb.Message.Chat.ID
 
-	if !isAdmin(userID) {
+	if !isAdmin(userID) && !isCustomerCallback(cb.Data) {
 		bot.Send(tgbotapi.NewMessage(chatID, "⛔ У вас нет доступа."))
 		return
 	}
 
 	switch {
 	case cb.Data == "create_client":
-		handleCreateClient(bot, chatID, userID)
+		handleCreateClient(ctx, bot, chatID, userID)
 
 	case cb.Data == "my_subs":
-		handleMySubs(bot, chatID, userID)
+		handleMySubs(ctx, bot, chatID, userID)
 
 	case cb.Data == "main_menu":
-		sendMainMenu(bot, chatID)
+		sendMainMenu(ctx, bot, chatID)
 
-	case strings.HasPrefix(cb.Data, "traffic_"):
-		handleTrafficChoice(bot, chatID, userID, cb.Data)
+	case strings.HasPrefix(cb.Data, "clone_"):
+		handleClone(ctx, bot, chatID, userID, strings.TrimPrefix(cb.Data, "clone_"))
 
-	case strings.HasPrefix(cb.Data, "expire_"):
-		handleExpireChoice(bot, chatID, userID, cb.Data)
-	}
-}
+	case strings.HasPrefix(cb.Data, "undo_"):
+		handleUndo(ctx, bot, chatID, userID, strings.TrimPrefix(cb.Data, "undo_"))
 
-func handleCreateClient(bot *tgbotapi.BotAPI, chatID, userID int64) {
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("50 GB", "traffic_50"),
-			tgbotapi.NewInlineKeyboardButtonData("100 GB", "traffic_100"),
-			tgbotapi.NewInlineKeyboardButtonData("200 GB", "traffic_200"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("500 GB", "traffic_500"),
-			tgbotapi.NewInlineKeyboardButtonData("♾ Безлимит", "traffic_0"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "main_menu"),
-		),
-	)
+	case strings.HasPrefix(cb.Data, "showlink_"):
+		handleShowLink(ctx, bot, chatID, userID, strings.TrimPrefix(cb.Data, "showlink_"))
 
-	msg := tgbotapi.NewMessage(chatID, "📊 *Выберите лимит трафика:*")
-	msg.ParseMode = "Markdown"
-	msg.ReplyMarkup = keyboard
-	bot.Send(msg)
+	case cb.Data == "viewas_ro":
+		bot.Send(tgbotapi.NewMessage(chatID, "👁 Это режим просмотра, кнопки не работают."))
 
-	statesMu.Lock()
-	userStates[userID] = &UserState{Step: "choosing_traffic"}
-	statesMu.Unlock()
-}
+	case strings.HasPrefix(cb.Data, "forget_"):
+		handleForgetCallback(ctx, bot, chatID, userID, cb.Data)
 
-func handleTrafficChoice(bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
-	gb, _ := strconv.Atoi(strings.TrimPrefix(data, "traffic_"))
+	case cb.Data == "batch_csv":
+		handleBatchCSV(ctx, bot, chatID, userID)
 
-	statesMu.Lock()
-	state, ok := userStates[userID]
-	if !ok {
-		state = &UserState{}
-		userStates[userID] = state
-	}
-	state.TrafficGB = gb
-	state.Step = "choosing_expire"
-	statesMu.Unlock()
+	case cb.Data == "settings":
+		sendSettingsMenu(ctx, bot, chatID, userID)
 
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("7 дней", "expire_7"),
-			tgbotapi.NewInlineKeyboardButtonData("30 дней", "expire_30"),
-			tgbotapi.NewInlineKeyboardButtonData("90 дней", "expire_90"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("180 дней", "expire_180"),
-			tgbotapi.NewInlineKeyboardButtonData("365 дней", "expire_365"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "create_client"),
-		),
-	)
+	case strings.HasPrefix(cb.Data, "pref_"):
+		handlePrefCallback(ctx, bot, chatID, userID, cb.Data)
 
-	trafficText := "♾ Безлимит"
-	if gb > 0 {
-		trafficText = fmt.Sprintf("%d GB", gb)
-	}
+	case cb.Data == "freeze":
+		handleFreeze(ctx, bot, chatID, userID)
 
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("📊 Трафик: *%s*\n\n⏳ *Выберите срок действия:*", trafficText))
-	msg.ParseMode = "Markdown"
-	msg.ReplyMarkup = keyboard
-	bot.Send(msg)
-}
+	case cb.Data == "freeze_confirm":
+		handleFreezeConfirm(ctx, bot, chatID, userID)
 
-func handleExpireChoice(bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
-	days, _ := strconv.Atoi(strings.TrimPrefix(data, "expire_"))
+	case cb.Data == "freeze_cancel":
+		handleFreezeCancel(ctx, bot, chatID, userID)
 
-	statesMu.Lock()
-	state, ok := userStates[userID]
-	if !ok {
-		statesMu.Unlock()
-		sendMainMenu(bot, chatID)
-		return
+	case strings.HasPrefix(cb.Data, "edit_"):
+		handleEditCallback(ctx, bot, chatID, userID, cb.Data)
+
+	case cb.Data == "resume":
+		handleResume(ctx, bot, chatID, userID)
+
+	case strings.HasPrefix(cb.Data, "family"):
+		handleFamilyCallback(ctx, bot, chatID, userID, cb.Data)
+
+	case cb.Data == "locations":
+		handleLocations(ctx, bot, chatID, userID)
+
+	case cb.Data == "claim_link":
+		startFlow(ctx, bot, chatID, userID, "claim_link", nil)
+
+	case strings.HasPrefix(cb.Data, "link_"):
+		if !isOwner(userID) {
+			bot.Send(tgbotapi.NewMessage(chatID, "⛔ У вас нет доступа."))
+			return
+		}
+		handleLinkCallback(ctx, bot, chatID, userID, cb.Data)
+
+	case strings.HasPrefix(cb.Data, "squad"):
+		handleSquadCallback(ctx, bot, chatID, userID, cb.Data)
+
+	case strings.HasPrefix(cb.Data, "host"):
+		handleHostCallback(ctx, bot, chatID, userID, cb.Data)
+
+	case cb.Data == "system":
+		sendSystemStats(ctx, bot, chatID)
+
+	case strings.HasPrefix(cb.Data, "node"):
+		handleNodeCallback(ctx, bot, chatID, userID, cb.Data)
+
+	case strings.HasPrefix(cb.Data, "wz:"):
+		// Flows check their own access
+		handleWizardCallback(ctx, bot, chatID, userID, cb.Data)
 	}
-	state.DaysExpire = days
-	state.Step = "entering_name"
-	statesMu.Unlock()
+}
 
-	msg := tgbotapi.NewMessage(chatID, "✏️ *Введите имя для клиента:*\n\nТолько латиница, цифры, дефис и подчёркивание.\nНапример: `Ivan` или `iPhone-Petya`")
-	msg.ParseMode = "Markdown"
-	bot.Send(msg)
+func handleCreateClient(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	startFlow(ctx, bot, chatID, userID, "create_client", nil)
 }
 
-func finishClientCreation(bot *tgbotapi.BotAPI, chatID, userID int64, clientName string, trafficGB, days int) {
-	// Send "creating..." message
-	waitMsg := tgbotapi.NewMessage(chatID, "⏳ Создаю клиента...")
-	bot.Send(waitMsg)
+// ClientParams describes a client to create, shared by the wizard and text commands
+type ClientParams struct {
+	Name       string    `json:"name"`
+	TrafficGB  int       `json:"trafficGb"`
+	Days       int       `json:"days"`
+	Squad      string    `json:"squad"`      // internal squad name, the default squad when empty
+	Devices    int       `json:"devices"`    // HWID device limit, unlimited when zero
+	TelegramID int64     `json:"telegramId"` // subscription owner, the creating admin when zero
+	Family     bool      `json:"family"`     // family member: no Telegram owner, tracked by the family, not a sale
+	StartAt    time.Time `json:"startAt"`
+	ExpireAt   time.Time `json:"expireAt"` // fixed expiry overriding Days, e.g. for family members
+
+	// Exact settings copied from another client, override Squad and the default inbounds
+	SquadUUIDs []string     `json:"squadUuids"`
+	Inbounds   []InboundTag `json:"inbounds"`
+	Tag        string       `json:"tag"`
+}
 
-	// Use client name directly as username
-	username := clientName
+// activationTime returns when the subscription starts counting its days
+func (p ClientParams) activationTime() time.Time {
+	if p.StartAt.After(time.Now()) {
+		return p.StartAt
+	}
+	return time.Now()
+}
 
-	// Calculate expiry
-	expireAt := time.Now().AddDate(0, 0, days).UTC().Format(time.RFC3339)
+func (p ClientParams) scheduled() bool {
+	return p.StartAt.After(time.Now())
+}
 
-	// Get available inbounds
-	inbounds, err := getInbounds()
-	if err != nil {
-		log.Printf("Failed to get inbounds: %v", err)
+func (p ClientParams) expireAt() time.Time {
+	if !p.ExpireAt.IsZero() {
+		return p.ExpireAt
 	}
+	return p.activationTime().AddDate(0, 0, p.Days)
+}
 
-	var inboundTags []InboundTag
-	for _, inb := range inbounds {
-		inboundTags = append(inboundTags, InboundTag{Tag: inb.Tag})
-	}
+var clientNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+func validClientName(name string) bool {
+	return name != "" && clientNameRe.MatchString(name)
+}
 
-	// Get default internal squad
-	var squadUUIDs []string
-	squads, err := getInternalSquads()
+// resolveSquads picks the internal squad by name, falling back to the default squad
+func resolveSquads(ctx context.Context, name string) ([]string, error) {
+	squads, err := getInternalSquads(ctx)
 	if err != nil {
+		if name != "" {
+			return nil, err
+		}
 		log.Printf("Failed to get internal squads: %v", err)
-	} else {
+		return nil, nil
+	}
+
+	if name != "" {
 		for _, sq := range squads {
-			if strings.EqualFold(sq.Name, "Default-Squad") || strings.EqualFold(sq.Name, "default") {
-				squadUUIDs = append(squadUUIDs, sq.UUID)
-				break
+			if strings.EqualFold(sq.Name, name) {
+				return []string{sq.UUID}, nil
 			}
 		}
-		// If no "Default" found, use the first squad
-		if len(squadUUIDs) == 0 && len(squads) > 0 {
-			squadUUIDs = append(squadUUIDs, squads[0].UUID)
+		return nil, fmt.Errorf("squad %q not found", name)
+	}
+
+	for _, sq := range squads {
+		if strings.EqualFold(sq.Name, "Default-Squad") || strings.EqualFold(sq.Name, "default") {
+			return []string{sq.UUID}, nil
+		}
+	}
+	// If no "Default" found, use the first squad
+	if len(squads) > 0 {
+		return []string{squads[0].UUID}, nil
+	}
+	return nil, nil
+}
+
+// createClient creates the Remnawave user and records the sale
+func createClient(ctx context.Context, params ClientParams, createdBy int64) (*RemnawaveUser, error) {
+	// Calculate expiry; scheduled clients get it recalculated on activation
+	expireAt := params.expireAt().UTC().Format(time.RFC3339)
+
+	inboundTags := params.Inbounds
+	if inboundTags == nil {
+		// Get available inbounds
+		inbounds, err := getInbounds(ctx)
+		if err != nil {
+			log.Printf("Failed to get inbounds: %v", err)
+		}
+
+		for _, inb := range inbounds {
+			inboundTags = append(inboundTags, InboundTag{Tag: inb.Tag})
+		}
+	}
+
+	squadUUIDs := params.SquadUUIDs
+	if squadUUIDs == nil {
+		var err error
+		if squadUUIDs, err = resolveSquads(ctx, params.Squad); err != nil {
+			return nil, err
 		}
 	}
 
+	telegramID := params.TelegramID
+	if telegramID == 0 && !params.Family {
+		telegramID = createdBy
+	}
+	description := fmt.Sprintf("Created by bot for TG user %d", telegramID)
+	if params.Family {
+		description = "Created by bot"
+	}
+
 	// Create user request
 	req := CreateUserRequest{
-		Username:             username,
+		Username:             params.Name,
 		ExpireAt:             expireAt,
-		TelegramID:           userID,
-		Description:          fmt.Sprintf("Created by bot for TG user %d", userID),
+		TelegramID:           telegramID,
+		Description:          description,
+		Tag:                  params.Tag,
+		HwidDeviceLimit:      params.Devices,
 		ActiveUserInbounds:   inboundTags,
 		ActiveInternalSquads: squadUUIDs,
 	}
 
-	if trafficGB > 0 {
-		req.TrafficLimitBytes = int64(trafficGB) * 1024 * 1024 * 1024
+	if params.TrafficGB > 0 {
+		req.TrafficLimitBytes = int64(params.TrafficGB) * 1024 * 1024 * 1024
+	}
+	if params.scheduled() {
+		req.Status = "DISABLED"
 	}
 
 	// Create user in Remnawave
-	user, err := createRemnawaveUser(req)
+	user, err := createRemnawaveUser(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.scheduled() {
+		// A disabled client nobody will activate is useless, take it back
+		if err := schedulePendingActivation(user, params, telegramID); err != nil {
+			log.Printf("Failed to schedule activation of %s: %v", user.Username, err)
+			if delErr := deleteRemnawaveUser(ctx, user.UUID); delErr != nil {
+				log.Printf("Failed to roll back %s: %v", user.Username, delErr)
+				return nil, fmt.Errorf("активация не запланирована (%v), клиент %s создан отключённым, удалите его вручную", err, user.Username)
+			}
+			return nil, fmt.Errorf("активация не запланирована, клиент не создан: %w", err)
+		}
+	}
+
+	record := &ClientRecord{
+		UUID:      user.UUID,
+		Username:  user.Username,
+		CreatedBy: createdBy,
+		TrafficGB: params.TrafficGB,
+		Days:      params.Days,
+		Family:    params.Family,
+		CreatedAt: time.Now(),
+	}
+	details := fmt.Sprintf("%s, %d days", trafficText(params.TrafficGB), params.Days)
+	if params.Family {
+		details = fmt.Sprintf("family member, %s until %s", trafficText(params.TrafficGB), params.ExpireAt.Format("2006-01-02"))
+	} else if plan := cfg.planFor(params.TrafficGB, params.Days); plan != nil {
+		record.Plan = plan.Name
+		record.Price = plan.Price
+		record.Currency = plan.Currency
+	}
+	if err := store.update(func(d *storeData) { d.Clients[user.UUID] = record }); err != nil {
+		log.Printf("Failed to save client record: %v", err)
+	}
+	recordAudit(createdBy, "create", user.Username, details)
+
+	return user, nil
+}
+
+func trafficText(trafficGB int) string {
+	if trafficGB > 0 {
+		return fmt.Sprintf("%d GB", trafficGB)
+	}
+	return "♾ Безлимит"
+}
+
+func finishClientCreation(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, params ClientParams) {
+	// Send "creating..." message
+	waitMsg := tgbotapi.NewMessage(chatID, "⏳ Создаю клиента...")
+	bot.Send(waitMsg)
+
+	user, err := createClient(ctx, params, userID)
 	if err != nil {
 		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка создания клиента:\n`%s`", err.Error()))
 		errMsg.ParseMode = "Markdown"
@@ -356,11 +828,11 @@ func finishClientCreation(bot *tgbotapi.BotAPI, chatID, userID int64, clientName
 	}
 
 	// Build subscription link
-	subLink := fmt.Sprintf("%s/api/sub/%s", subDomain, user.ShortUUID)
+	subLink := subscriptionLink(user.ShortUUID)
 
-	trafficText := "♾ Безлимит"
-	if trafficGB > 0 {
-		trafficText = fmt.Sprintf("%d GB", trafficGB)
+	activationText := ""
+	if params.scheduled() {
+		activationText = fmt.Sprintf("🕒 Активация: *%s*\n", params.StartAt.Format("02.01.2006"))
 	}
 
 	resultText := fmt.Sprintf(
@@ -368,17 +840,25 @@ func finishClientCreation(bot *tgbotapi.BotAPI, chatID, userID int64, clientName
 			"👤 Имя: `%s`\n"+
 			"📊 Трафик: *%s*\n"+
 			"⏳ Срок: *%d дней*\n"+
+			"%s"+
 			"📅 Истекает: *%s*\n\n"+
 			"🔗 *Ссылка на подписку:*\n`%s`\n\n"+
 			"Скопируйте ссылку и вставьте в ваш VPN-клиент.",
 		user.Username,
-		trafficText,
-		days,
-		time.Now().AddDate(0, 0, days).Format("02.01.2006"),
+		trafficText(params.TrafficGB),
+		params.Days,
+		activationText,
+		params.expireAt().Format("02.01.2006"),
 		subLink,
 	)
 
+	rememberCreation(user, params, userID)
+
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↩️ Отменить", "undo_"+user.UUID),
+			tgbotapi.NewInlineKeyboardButtonData("📑 Клонировать", "clone_"+user.UUID),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("⬅️ Главное меню", "main_menu"),
 		),
@@ -387,27 +867,38 @@ func finishClientCreation(bot *tgbotapi.BotAPI, chatID, userID int64, clientName
 	msg := tgbotapi.NewMessage(chatID, resultText)
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = keyboard
-	bot.Send(msg)
+	sendLinkMessage(bot, msg, user.UUID, user.Username)
 }
 
-func handleMySubs(bot *tgbotapi.BotAPI, chatID, userID int64) {
-	user, err := getUserByTelegramID(userID)
+func handleMySubs(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	user, err := getUserByTelegramID(ctx, userID)
 	if err != nil {
 		keyboard := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("➕ Создать клиента", "create_client"),
 			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🔗 Привязать по ссылке", "claim_link"),
+			),
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "main_menu"),
 			),
 		)
-		msg := tgbotapi.NewMessage(chatID, "📋 У вас пока нет подписок.")
+		addFamilyButton(&keyboard, userID)
+		msg := tgbotapi.NewMessage(chatID, "📋 У вас пока нет подписок.\n\nЕсли подписка уже есть, привяжите её по ссылке.")
 		msg.ReplyMarkup = keyboard
 		bot.Send(msg)
 		return
 	}
 
-	subLink := fmt.Sprintf("%s/api/sub/%s", subDomain, user.ShortUUID)
+	subLink := subscriptionLink(user.ShortUUID)
+
+	status := user.Status
+	freezeButton := tgbotapi.NewInlineKeyboardButtonData("❄️ Заморозить", "freeze")
+	if freeze := freezeStateFor(user.UUID); freeze.frozen() {
+		status = fmt.Sprintf("заморожена с %s", freeze.FrozenAt.Format("02.01.2006"))
+		freezeButton = tgbotapi.NewInlineKeyboardButtonData("▶️ Возобновить", "resume")
+	}
 
 	text := fmt.Sprintf(
 		"📋 *Ваша подписка:*\n\n"+
@@ -415,55 +906,81 @@ func handleMySubs(bot *tgbotapi.BotAPI, chatID, userID int64) {
 			"📊 Статус: *%s*\n\n"+
 			"🔗 *Ссылка:*\n`%s`",
 		user.Username,
-		user.Status,
+		status,
 		subLink,
 	)
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌍 Локации", "locations"),
+			freezeButton,
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "main_menu"),
 		),
 	)
+	addFamilyButton(&keyboard, userID)
+	if isAdmin(userID) {
+		row := tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📑 Клонировать", "clone_"+user.UUID),
+		)
+		keyboard.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{row}, keyboard.InlineKeyboard...)
+	}
 
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = keyboard
-	bot.Send(msg)
+	sendLinkMessage(bot, msg, user.UUID, user.Username)
+}
+
+func subscriptionLink(shortUUID string) string {
+	return fmt.Sprintf("%s/api/sub/%s", subDomain, shortUUID)
 }
 
-func handleText(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
-	userID := msg.From.ID
-	chatID := msg.Chat.ID
+func handleText(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	ctx, span := tracer.Start(ctx, "message")
+	defer span.End()
+	bot = tracedBot(ctx, bot)
 
-	statesMu.Lock()
-	state, ok := userStates[userID]
-	if !ok || state.Step != "entering_name" {
-		statesMu.Unlock()
-		sendMainMenu(bot, chatID)
+	if handleWizardMessage(ctx, bot, msg) {
 		return
 	}
-	trafficGB := state.TrafficGB
-	days := state.DaysExpire
-	clientName := strings.TrimSpace(msg.Text)
-	delete(userStates, userID)
-	statesMu.Unlock()
-
-	validName := regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
-	if clientName == "" || !validName.MatchString(clientName) {
-		m := tgbotapi.NewMessage(chatID, "❌ Имя должно содержать только латиницу, цифры, дефис или подчёркивание.\nПопробуйте ещё раз:")
-		bot.Send(m)
-		statesMu.Lock()
-		userStates[userID] = &UserState{Step: "entering_name", TrafficGB: trafficGB, DaysExpire: days}
-		statesMu.Unlock()
+	// Groups talk among themselves, the bot only answers when addressed
+	if !msg.Chat.IsPrivate() && !addressedToBot(bot, msg) {
 		return
 	}
-
-	finishClientCreation(bot, chatID, userID, clientName, trafficGB, days)
+	sendMainMenu(ctx, bot, msg.Chat.ID)
 }
 
 // Remnawave API calls
 
-func remnawaveRequest(method, path string, body interface{}) ([]byte, error) {
+var pathIDRe = regexp.MustCompile(`^([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|\d+)$`)
+
+// pathTemplate replaces IDs and names in an API path with a placeholder, for traces
+func pathTemplate(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if pathIDRe.MatchString(p) || (i > 0 && strings.HasPrefix(parts[i-1], "by-")) {
+			parts[i] = "{id}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func remnawaveRequest(ctx context.Context, method, path string, body interface{}) (result []byte, err error) {
+	ctx, span := tracer.Start(ctx, "remnawave "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("url.path", pathTemplate(path))),
+	)
+	defer func() {
+		if err != nil {
+			failSpan(span, err)
+			err = withTraceID(ctx, err)
+		}
+		span.End()
+	}()
+
 	var reqBody io.Reader
 	if body != nil {
 		data, err := json.Marshal(body)
@@ -473,7 +990,7 @@ func remnawaveRequest(method, path string, body interface{}) ([]byte, error) {
 		reqBody = bytes.NewReader(data)
 	}
 
-	req, err := http.NewRequest(method, remnawaveAPI+path, reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, remnawaveAPI+path, reqBody)
 	if err != nil {
 		return nil, err
 	}
@@ -491,6 +1008,7 @@ func remnawaveRequest(method, path string, body interface{}) ([]byte, error) {
 		return nil, err
 	}
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 	if resp.StatusCode >= 400 {
 		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
 	}
@@ -498,8 +1016,22 @@ func remnawaveRequest(method, path string, body interface{}) ([]byte, error) {
 	return respBody, nil
 }
 
-func createRemnawaveUser(user CreateUserRequest) (*RemnawaveUser, error) {
-	data, err := remnawaveRequest("POST", "/api/users", user)
+func createRemnawaveUser(ctx context.Context, user CreateUserRequest) (*RemnawaveUser, error) {
+	data, err := remnawaveRequest(ctx, "POST", "/api/users", user)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp RemnawaveResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &resp.Response, nil
+}
+
+func getUserByTelegramID(ctx context.Context, telegramID int64) (*RemnawaveUser, error) {
+	data, err := remnawaveRequest(ctx, "GET", fmt.Sprintf("/api/users/by-telegram-id/%d", telegramID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -512,8 +1044,8 @@ func createRemnawaveUser(user CreateUserRequest) (*RemnawaveUser, error) {
 	return &resp.Response, nil
 }
 
-func getUserByTelegramID(telegramID int64) (*RemnawaveUser, error) {
-	data, err := remnawaveRequest("GET", fmt.Sprintf("/api/users/by-telegram-id/%d", telegramID), nil)
+func getUserByUsername(ctx context.Context, username string) (*RemnawaveUser, error) {
+	data, err := remnawaveRequest(ctx, "GET", "/api/users/by-username/"+url.PathEscape(username), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -526,8 +1058,113 @@ func getUserByTelegramID(telegramID int64) (*RemnawaveUser, error) {
 	return &resp.Response, nil
 }
 
-func getInternalSquads() ([]InternalSquad, error) {
-	data, err := remnawaveRequest("GET", "/api/internal-squads", nil)
+func getUserByUUID(ctx context.Context, uuid string) (*RemnawaveUser, error) {
+	data, err := remnawaveRequest(ctx, "GET", "/api/users/"+url.PathEscape(uuid), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp RemnawaveResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &resp.Response, nil
+}
+
+func deleteRemnawaveUser(ctx context.Context, uuid string) error {
+	_, err := remnawaveRequest(ctx, "DELETE", "/api/users/"+url.PathEscape(uuid), nil)
+	return err
+}
+
+// revokeUserSubscription issues a new subscription link, invalidating the old one
+func revokeUserSubscription(ctx context.Context, uuid string) (*RemnawaveUser, error) {
+	data, err := remnawaveRequest(ctx, "POST", fmt.Sprintf("/api/users/%s/actions/revoke", url.PathEscape(uuid)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp RemnawaveResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &resp.Response, nil
+}
+
+func setUserEnabled(ctx context.Context, uuid string, enabled bool) error {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	_, err := remnawaveRequest(ctx, "POST", fmt.Sprintf("/api/users/%s/actions/%s", url.PathEscape(uuid), action), nil)
+	return err
+}
+
+// nodeAction runs enable, disable, restart or reset-traffic on a node
+func nodeAction(ctx context.Context, uuid, action string) error {
+	_, err := remnawaveRequest(ctx, "POST", fmt.Sprintf("/api/nodes/%s/actions/%s", url.PathEscape(uuid), action), nil)
+	return err
+}
+
+func getUserByShortUUID(ctx context.Context, shortUUID string) (*RemnawaveUser, error) {
+	data, err := remnawaveRequest(ctx, "GET", "/api/users/by-short-uuid/"+url.PathEscape(shortUUID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp RemnawaveResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &resp.Response, nil
+}
+
+// getAllUsers pages through every user in the panel
+func getAllUsers(ctx context.Context) ([]RemnawaveUser, error) {
+	const pageSize = 500
+
+	var users []RemnawaveUser
+	for start := 0; ; start += pageSize {
+		data, err := remnawaveRequest(ctx, "GET", fmt.Sprintf("/api/users?size=%d&start=%d", pageSize, start), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp UsersPageResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		users = append(users, resp.Response.Users...)
+		if len(resp.Response.Users) < pageSize || len(users) >= resp.Response.Total {
+			return users, nil
+		}
+	}
+}
+
+func updateRemnawaveUser(ctx context.Context, req UpdateUserRequest) (*RemnawaveUser, error) {
+	data, err := remnawaveRequest(ctx, "PATCH", "/api/users", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp RemnawaveResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &resp.Response, nil
+}
+
+func unlinkTelegramID(ctx context.Context, uuid string) error {
+	_, err := remnawaveRequest(ctx, "PATCH", "/api/users", UnlinkTelegramRequest{UUID: uuid})
+	return err
+}
+
+func getInternalSquads(ctx context.Context) ([]InternalSquad, error) {
+	data, err := remnawaveRequest(ctx, "GET", "/api/internal-squads", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -571,11 +1208,55 @@ func getInternalSquads() ([]InternalSquad, error) {
 	return nil, fmt.Errorf("no squads found in response: %s", string(data))
 }
 
-func getInbounds() ([]Inbound, error) {
-	data, err := remnawaveRequest("GET", "/api/inbounds", nil)
+func squadRequest(ctx context.Context, method string, req interface{}) (*InternalSquad, error) {
+	data, err := remnawaveRequest(ctx, method, "/api/internal-squads", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp InternalSquadResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &resp.Response, nil
+}
+
+func createInternalSquad(ctx context.Context, name string, inboundUUIDs []string) (*InternalSquad, error) {
+	if inboundUUIDs == nil {
+		inboundUUIDs = []string{}
+	}
+	return squadRequest(ctx, "POST", CreateSquadRequest{Name: name, Inbounds: inboundUUIDs})
+}
+
+func renameInternalSquad(ctx context.Context, uuid, name string) (*InternalSquad, error) {
+	return squadRequest(ctx, "PATCH", UpdateSquadRequest{UUID: uuid, Name: name})
+}
+
+func deleteInternalSquad(ctx context.Context, uuid string) error {
+	_, err := remnawaveRequest(ctx, "DELETE", "/api/internal-squads/"+url.PathEscape(uuid), nil)
+	return err
+}
+
+func getNodes(ctx context.Context) ([]Node, error) {
+	data, err := remnawaveRequest(ctx, "GET", "/api/nodes", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp NodesResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return resp.Response, nil
+}
+
+func getInbounds(ctx context.Context) ([]Inbound, error) {
+	data, err := remnawaveRequest(ctx, "GET", "/api/inbounds", nil)
 	if err != nil {
 		log.Printf("Failed /api/inbounds, trying /api/hosts: %v", err)
-		data, err = remnawaveRequest("GET", "/api/hosts", nil)
+		data, err = remnawaveRequest(ctx, "GET", "/api/hosts", nil)
 		if err != nil {
 			return nil, err
 		}
@@ -590,3 +1271,54 @@ func getInbounds() ([]Inbound, error) {
 
 	return resp.Response, nil
 }
+
+func getHosts(ctx context.Context) ([]Host, error) {
+	data, err := remnawaveRequest(ctx, "GET", "/api/hosts", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp HostsResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return resp.Response, nil
+}
+
+func updateHost(ctx context.Context, req UpdateHostRequest) error {
+	_, err := remnawaveRequest(ctx, "PATCH", "/api/hosts", req)
+	return err
+}
+
+func getSystemStats(ctx context.Context) (*SystemStats, error) {
+	data, err := remnawaveRequest(ctx, "GET", "/api/system/stats", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Response SystemStats `json:"response"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &resp.Response, nil
+}
+
+func getBandwidthStats(ctx context.Context) (*BandwidthStats, error) {
+	data, err := remnawaveRequest(ctx, "GET", "/api/system/stats/bandwidth", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Response BandwidthStats `json:"response"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &resp.Response, nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Node management: everyone with admin rights sees the nodes, only owners act on them
+type NodeAction struct {
+	Endpoint string // panel action path segment
+	Label    string
+	Confirm  string // confirmation question, %s is the node name
+	Done     string
+}
+
+var nodeActions = map[string]NodeAction{
+	"enable": {
+		Endpoint: "enable",
+		Label:    "🟢 Включить",
+		Confirm:  "Включить ноду `%s`?",
+		Done:     "🟢 Нода включена.",
+	},
+	"disable": {
+		Endpoint: "disable",
+		Label:    "🔴 Выключить",
+		Confirm:  "Выключить ноду `%s`? Подключённые к ней пользователи потеряют соединение.",
+		Done:     "🔴 Нода выключена.",
+	},
+	"restart": {
+		Endpoint: "restart",
+		Label:    "🔄 Перезапустить",
+		Confirm:  "Перезапустить ноду `%s`? Пользователи будут отключены на несколько секунд.",
+		Done:     "🔄 Перезапуск отправлен.",
+	},
+	"reset": {
+		Endpoint: "reset-traffic",
+		Label:    "🧹 Сбросить трафик",
+		Confirm:  "Сбросить счётчик трафика ноды `%s`?",
+		Done:     "🧹 Трафик ноды сброшен.",
+	},
+}
+
+func findNode(ctx context.Context, uuid string) (*Node, error) {
+	nodes, err := getNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		if nodes[i].UUID == uuid {
+			return &nodes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("node %s not found", uuid)
+}
+
+func nodeStatus(n *Node) string {
+	switch {
+	case n.IsDisabled:
+		return "⚪"
+	case n.IsConnected:
+		return "🟢"
+	default:
+		return "🔴"
+	}
+}
+
+func nodeTraffic(n *Node) string {
+	if n.TrafficLimitBytes > 0 {
+		return fmt.Sprintf("%s из %s", formatBytes(n.TrafficUsedBytes), formatBytes(n.TrafficLimitBytes))
+	}
+	return formatBytes(n.TrafficUsedBytes)
+}
+
+func sendNodesMenu(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	nodes, err := getNodes(ctx)
+	if err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка получения нод:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🖥 *Ноды*\n\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := range nodes {
+		n := &nodes[i]
+		fmt.Fprintf(&sb, "%s `%s` — онлайн: *%d*, трафик: *%s*\n", nodeStatus(n), codeText(n.Name), n.UsersOnline, nodeTraffic(n))
+		if isOwner(userID) {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(nodeStatus(n)+" "+n.Name, "node_view_"+n.UUID),
+			))
+		}
+	}
+	if len(nodes) == 0 {
+		sb.WriteString("Нод нет.")
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Обновить", "nodes"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Главное меню", "main_menu"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bot.Send(msg)
+}
+
+func handleNodeCallback(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
+	if data == "nodes" {
+		sendNodesMenu(ctx, bot, chatID, userID)
+		return
+	}
+	if !isOwner(userID) {
+		bot.Send(tgbotapi.NewMessage(chatID, "⛔ У вас нет доступа."))
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(data, "node_view_"):
+		sendNodeView(ctx, bot, chatID, strings.TrimPrefix(data, "node_view_"))
+
+	case strings.HasPrefix(data, "node_ask_"):
+		name, uuid, _ := strings.Cut(strings.TrimPrefix(data, "node_ask_"), "_")
+		confirmNodeAction(ctx, bot, chatID, name, uuid)
+
+	case strings.HasPrefix(data, "node_do_"):
+		name, uuid, _ := strings.Cut(strings.TrimPrefix(data, "node_do_"), "_")
+		runNodeAction(ctx, bot, chatID, userID, name, uuid)
+	}
+}
+
+func sendNodeView(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, uuid string) {
+	node, err := findNode(ctx, uuid)
+	if err != nil {
+		log.Printf("Failed to get node %s: %v", uuid, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Нода не найдена."))
+		return
+	}
+
+	state := "подключена"
+	switch {
+	case node.IsDisabled:
+		state = "выключена"
+	case !node.IsConnected:
+		state = "нет связи"
+	}
+	version := node.XrayVersion
+	if version == "" {
+		version = "—"
+	}
+
+	toggle := "disable"
+	if node.IsDisabled {
+		toggle = "enable"
+	}
+	button := func(action string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(nodeActions[action].Label, "node_ask_"+action+"_"+node.UUID)
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(button(toggle), button("restart")),
+		tgbotapi.NewInlineKeyboardRow(button("reset")),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "nodes"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"🖥 *Нода* `%s`\n\n"+
+			"%s Состояние: *%s*\n"+
+			"📍 Адрес: `%s`\n"+
+			"🧩 Xray: `%s`\n"+
+			"👥 Онлайн: *%d*\n"+
+			"📊 Трафик: *%s*",
+		codeText(node.Name), nodeStatus(node), state, node.Address, version, node.UsersOnline, nodeTraffic(node),
+	))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+func confirmNodeAction(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, name, uuid string) {
+	action, ok := nodeActions[name]
+	if !ok {
+		return
+	}
+	node, err := findNode(ctx, uuid)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Нода не найдена."))
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить", "node_do_"+name+"_"+uuid),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "node_view_"+uuid),
+		),
+	)
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(action.Confirm, codeText(node.Name)))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+func runNodeAction(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, name, uuid string) {
+	action, ok := nodeActions[name]
+	if !ok {
+		return
+	}
+	node, err := findNode(ctx, uuid)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Нода не найдена."))
+		return
+	}
+
+	if err := nodeAction(ctx, uuid, action.Endpoint); err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка действия с нодой:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+	recordAudit(userID, "node_"+name, node.Name, "")
+	log.Printf("User %d: node %s %s", userID, name, node.Name)
+
+	bot.Send(tgbotapi.NewMessage(chatID, action.Done))
+	sendNodeView(ctx, bot, chatID, uuid)
+}
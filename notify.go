@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Notification kinds users can switch off; service messages are always delivered
+const (
+	notifyExpiry       = "expiry"
+	notifyTraffic      = "traffic"
+	notifyAnnounce     = "announce"
+	notifySubscription = "subscription"
+)
+
+const defaultTimezone = "Europe/Moscow"
+
+type NotifyPrefs struct {
+	ExpiryReminders bool   `json:"expiryReminders"`
+	TrafficAlerts   bool   `json:"trafficAlerts"`
+	Announcements   bool   `json:"announcements"`
+	QuietFrom       int    `json:"quietFrom"` // local hour, quiet hours are off when equal to QuietTo
+	QuietTo         int    `json:"quietTo"`
+	Timezone        string `json:"timezone"`
+}
+
+type PendingNotification struct {
+	UserID    int64     `json:"userId"`
+	Kind      string    `json:"kind"`
+	Text      string    `json:"text"`
+	DeliverAt time.Time `json:"deliverAt"`
+}
+
+func defaultNotifyPrefs() NotifyPrefs {
+	return NotifyPrefs{
+		ExpiryReminders: true,
+		TrafficAlerts:   true,
+		Announcements:   true,
+		Timezone:        defaultTimezone,
+	}
+}
+
+func prefsFor(userID int64) NotifyPrefs {
+	prefs := defaultNotifyPrefs()
+	store.view(func(d *storeData) {
+		if p, ok := d.Preferences[userID]; ok {
+			prefs = *p
+		}
+	})
+	return prefs
+}
+
+func (p NotifyPrefs) allows(kind string) bool {
+	switch kind {
+	case notifyExpiry:
+		return p.ExpiryReminders
+	case notifyTraffic:
+		return p.TrafficAlerts
+	case notifyAnnounce:
+		return p.Announcements
+	}
+	return true
+}
+
+func (p NotifyPrefs) location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc, _ = time.LoadLocation(defaultTimezone)
+	}
+	return loc
+}
+
+// quietUntil returns when the current quiet period ends, or zero time if it's not quiet now
+func (p NotifyPrefs) quietUntil(now time.Time) time.Time {
+	if p.QuietFrom == p.QuietTo {
+		return time.Time{}
+	}
+
+	local := now.In(p.location())
+	h := local.Hour()
+
+	quiet := false
+	if p.QuietFrom < p.QuietTo {
+		quiet = h >= p.QuietFrom && h < p.QuietTo
+	} else {
+		quiet = h >= p.QuietFrom || h < p.QuietTo
+	}
+	if !quiet {
+		return time.Time{}
+	}
+
+	end := time.Date(local.Year(), local.Month(), local.Day(), p.QuietTo, 0, 0, 0, local.Location())
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+func (p NotifyPrefs) quietText() string {
+	if p.QuietFrom == p.QuietTo {
+		return "выкл."
+	}
+	return fmt.Sprintf("%02d:00–%02d:00", p.QuietFrom, p.QuietTo)
+}
+
+// notifyUser is the single entry point for unsolicited messages: it respects
+// the user's preferences and defers delivery until quiet hours are over
+func notifyUser(ctx context.Context, bot *tgbotapi.BotAPI, userID int64, kind, text string) {
+	prefs := prefsFor(userID)
+	if !prefs.allows(kind) {
+		return
+	}
+
+	if until := prefs.quietUntil(time.Now()); !until.IsZero() {
+		err := store.update(func(d *storeData) {
+			d.PendingNotifications = append(d.PendingNotifications, &PendingNotification{
+				UserID:    userID,
+				Kind:      kind,
+				Text:      text,
+				DeliverAt: until,
+			})
+		})
+		if err != nil {
+			log.Printf("Failed to defer notification for %d: %v", userID, err)
+		}
+		return
+	}
+
+	sendNotification(ctx, bot, userID, text)
+}
+
+func sendNotification(ctx context.Context, bot *tgbotapi.BotAPI, userID int64, text string) {
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ParseMode = "Markdown"
+	if _, err := bot.Send(msg); err != nil {
+		log.Printf("Failed to notify %d: %v", userID, err)
+	}
+}
+
+func deliverPendingNotifications(ctx context.Context, bot *tgbotapi.BotAPI, now time.Time) {
+	var due []*PendingNotification
+	err := store.update(func(d *storeData) {
+		var rest []*PendingNotification
+		for _, n := range d.PendingNotifications {
+			if n.DeliverAt.After(now) {
+				rest = append(rest, n)
+			} else {
+				due = append(due, n)
+			}
+		}
+		d.PendingNotifications = rest
+	})
+	if err != nil {
+		log.Printf("Failed to save pending notifications: %v", err)
+	}
+
+	for _, n := range due {
+		// Preferences may have changed while the message was waiting
+		if prefsFor(n.UserID).allows(n.Kind) {
+			sendNotification(ctx, bot, n.UserID, n.Text)
+		}
+	}
+}
+
+const usageNotifyInterval = time.Hour
+
+// runUsageNotifications sends expiry reminders and traffic alerts once an hour. The last run
+// is kept in the store, so missed ticks and restarts don't skip an hour.
+func runUsageNotifications(ctx context.Context, bot *tgbotapi.BotAPI, now time.Time) {
+	var lastRun time.Time
+	store.view(func(d *storeData) { lastRun = d.UsageNotifiedAt })
+	if now.Sub(lastRun) < usageNotifyInterval {
+		return
+	}
+
+	users, err := getAllUsers(ctx)
+	if err != nil {
+		log.Printf("Usage notifications: failed to get users: %v", err)
+		return
+	}
+
+	const (
+		reminderBefore = 3 * 24 * time.Hour
+		trafficAlertAt = 0.9
+	)
+
+	var expiring, overTraffic []RemnawaveUser
+	var underTraffic []string
+	store.view(func(d *storeData) {
+		for _, u := range users {
+			if u.TelegramID == nil || *u.TelegramID == 0 || u.Status != "ACTIVE" {
+				continue
+			}
+
+			left := u.ExpireAt.Sub(now)
+			if left > 0 && left <= reminderBefore && !d.ExpiryReminded[u.UUID].Equal(u.ExpireAt) {
+				expiring = append(expiring, u)
+			}
+
+			if u.TrafficLimitBytes > 0 {
+				used := float64(u.UsedTrafficBytes) / float64(u.TrafficLimitBytes)
+				switch {
+				case used >= trafficAlertAt && !d.TrafficAlerted[u.UUID]:
+					overTraffic = append(overTraffic, u)
+				case used < trafficAlertAt && d.TrafficAlerted[u.UUID]:
+					underTraffic = append(underTraffic, u.UUID) // traffic was reset, alert again next time
+				}
+			}
+		}
+	})
+
+	for _, u := range expiring {
+		notifyUser(ctx, bot, *u.TelegramID, notifyExpiry, fmt.Sprintf(
+			"⏳ Подписка `%s` истекает *%s*.",
+			u.Username,
+			u.ExpireAt.In(prefsFor(*u.TelegramID).location()).Format("02.01.2006 15:04"),
+		))
+	}
+	for _, u := range overTraffic {
+		notifyUser(ctx, bot, *u.TelegramID, notifyTraffic, fmt.Sprintf(
+			"📊 Подписка `%s` израсходовала %d%% трафика.",
+			u.Username,
+			u.UsedTrafficBytes*100/u.TrafficLimitBytes,
+		))
+	}
+
+	err = store.update(func(d *storeData) {
+		for _, u := range expiring {
+			d.ExpiryReminded[u.UUID] = u.ExpireAt
+		}
+		for _, u := range overTraffic {
+			d.TrafficAlerted[u.UUID] = true
+		}
+		for _, uuid := range underTraffic {
+			delete(d.TrafficAlerted, uuid)
+		}
+		d.UsageNotifiedAt = now
+	})
+	if err != nil {
+		log.Printf("Failed to save notification state: %v", err)
+	}
+}
+
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+// escapeMarkdown makes free-form text safe to send with the Markdown parse mode
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// codeText makes text safe inside a Markdown code span, where nothing can be escaped
+func codeText(text string) string {
+	return strings.ReplaceAll(text, "`", "'")
+}
+
+func handleAnnounceCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	text := strings.TrimSpace(msg.CommandArguments())
+	if text == "" {
+		m := tgbotapi.NewMessage(msg.Chat.ID, "Использование: `/announce <текст>`")
+		m.ParseMode = "Markdown"
+		bot.Send(m)
+		return
+	}
+
+	var recipients []int64
+	store.view(func(d *storeData) {
+		for id := range d.BotUsers {
+			recipients = append(recipients, id)
+		}
+	})
+
+	// The broadcast outlives the handler: it gets its own span and only uses
+	// values read here, not the handler's ctx, bot or config
+	announcement := "📢 " + escapeMarkdown(text)
+	bctx, span := tracer.Start(context.WithoutCancel(ctx), "announce broadcast",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(attribute.Int("announce.recipients", len(recipients))))
+	go func() {
+		defer span.End()
+		b := tracedBot(bctx, bot)
+		for _, id := range recipients {
+			notifyUser(bctx, b, id, notifyAnnounce, announcement)
+			time.Sleep(50 * time.Millisecond) // stay under Telegram's broadcast limits
+		}
+		log.Printf("Announcement sent to %d users", len(recipients))
+	}()
+
+	bot.Send(tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("📢 Рассылка запущена: %d получателей.", len(recipients))))
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Config hot reload. Handlers and scheduler ticks hold configMu for reading while they run,
+// so a reload waits for them to finish on the old config and new updates see the new one.
+var configMu sync.RWMutex
+
+const configCheckInterval = 10 * time.Second
+
+// restartOnlyKeys are config sections read once at startup
+var restartOnlyKeys = map[string]bool{"encryption": true}
+
+// watchConfig reloads the config on SIGHUP and, with reloadOnChange, when the file changes
+func watchConfig(bot *tgbotapi.BotAPI) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		ticker := time.NewTicker(configCheckInterval)
+		defer ticker.Stop()
+		modTime := configModTime()
+
+		for {
+			select {
+			case <-hup:
+				modTime = configModTime()
+				reloadConfig(bot, "SIGHUP")
+
+			case <-ticker.C:
+				configMu.RLock()
+				watch := cfg.ReloadOnChange
+				configMu.RUnlock()
+
+				if t := configModTime(); watch && !t.Equal(modTime) {
+					modTime = t
+					reloadConfig(bot, "изменение файла")
+				}
+			}
+		}
+	}()
+}
+
+func configModTime() time.Time {
+	info, err := os.Stat(configFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func reloadConfig(bot *tgbotapi.BotAPI, reason string) {
+	ctx, span := tracer.Start(context.Background(), "config reload")
+	defer span.End()
+	bot = tracedBot(ctx, bot)
+
+	// loadConfig validates everything, a broken file leaves the running config untouched.
+	// A missing file would silently reset everything, so it's an error here.
+	_, err := os.Stat(configFile)
+	var next *Config
+	if err == nil {
+		next, err = loadConfig(configFile)
+	}
+	if err != nil {
+		failSpan(span, err)
+		log.Printf("Config reload (%s) failed: %v", reason, err)
+		notifyOwners(bot, fmt.Sprintf("❌ Конфигурация не перезагружена (%s):\n`%s`", reason, err.Error()))
+		return
+	}
+
+	configMu.Lock()
+	prev := cfg
+	cfg = next
+	configMu.Unlock()
+
+	changes := configDiff(prev, next)
+	log.Printf("Config reloaded (%s): %d sections changed", reason, len(changes))
+	if len(changes) == 0 {
+		notifyOwners(bot, fmt.Sprintf("🔄 Конфигурация перезагружена (%s), изменений нет.", reason))
+		return
+	}
+	notifyOwners(bot, fmt.Sprintf("🔄 *Конфигурация перезагружена* (%s)\n\n%s", reason, strings.Join(changes, "\n")))
+}
+
+// configDiffLimit keeps the owners' message well within Telegram's size limit
+const configDiffLimit = 30
+
+// configDiff lists changed config keys with their old and new values, secrets redacted
+func configDiff(prev, next *Config) []string {
+	before, after := flattenConfig(prev), flattenConfig(next)
+
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []string
+	for _, k := range keys {
+		old, hadOld := before[k]
+		val, hasNew := after[k]
+		if hadOld && hasNew && old == val {
+			continue
+		}
+		if len(changes) == configDiffLimit {
+			changes = append(changes, "…и другие изменения")
+			break
+		}
+
+		if secretConfigKey(k) {
+			old, val = "•••", "•••"
+		}
+		var change string
+		switch {
+		case secretConfigKey(k) && hadOld && hasNew:
+			change = "изменено, значение скрыто"
+		case !hadOld:
+			change = fmt.Sprintf("добавлено `%s`", codeText(val))
+		case !hasNew:
+			change = fmt.Sprintf("удалено (было `%s`)", codeText(old))
+		default:
+			change = fmt.Sprintf("`%s` → `%s`", codeText(old), codeText(val))
+		}
+		section, _, _ := strings.Cut(strings.SplitN(k, "[", 2)[0], ".")
+		if restartOnlyKeys[section] {
+			change += ", применится после перезапуска"
+		}
+		changes = append(changes, fmt.Sprintf("• `%s`: %s", codeText(k), change))
+	}
+	return changes
+}
+
+// flattenConfig turns the config into "path: JSON value" pairs by its JSON names. List items
+// are addressed by their name when they have one, lists of plain values are one value.
+func flattenConfig(c *Config) map[string]string {
+	raw, _ := json.Marshal(c)
+	var tree any
+	json.Unmarshal(raw, &tree)
+
+	flat := make(map[string]string)
+	var walk func(path string, v any)
+	walk = func(path string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				if path == "" {
+					walk(k, child)
+				} else {
+					walk(path+"."+k, child)
+				}
+			}
+		case []any:
+			objects := len(v) > 0
+			for _, item := range v {
+				if _, ok := item.(map[string]any); !ok {
+					objects = false
+				}
+			}
+			if !objects {
+				b, _ := json.Marshal(v)
+				flat[path] = string(b)
+				return
+			}
+			for i, item := range v {
+				key := strconv.Itoa(i)
+				if name, ok := item.(map[string]any)["name"].(string); ok && name != "" {
+					key = name
+				}
+				walk(path+"["+key+"]", item)
+			}
+		case nil:
+			// absent sections and values don't show up
+		default:
+			b, _ := json.Marshal(v)
+			flat[path] = string(b)
+		}
+	}
+	walk("", tree)
+	return flat
+}
+
+// secretConfigKey tells keys whose values must not be sent to chats
+func secretConfigKey(path string) bool {
+	name := strings.ToLower(path[strings.LastIndexAny(path, ".]")+1:])
+	for _, word := range []string{"key", "token", "secret", "password"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyOwners sends a service message to the owners from OWNER_IDS and the config, or to
+// the admins when no owners are configured, as isOwner does
+func notifyOwners(bot *tgbotapi.BotAPI, text string) {
+	configMu.RLock()
+	ids := make(map[int64]bool, len(ownerIDs))
+	for id := range ownerIDs {
+		ids[id] = true
+	}
+	for _, id := range cfg.Owners {
+		ids[id] = true
+	}
+	if len(ids) == 0 {
+		for id := range adminIDs {
+			ids[id] = true
+		}
+		for _, id := range cfg.Admins {
+			ids[id] = true
+		}
+	}
+	configMu.RUnlock()
+
+	if len(ids) == 0 {
+		log.Printf("No owners or admins to notify: %s", text)
+		return
+	}
+
+	for id := range ids {
+		msg := tgbotapi.NewMessage(id, text)
+		msg.ParseMode = "Markdown"
+		if _, err := bot.Send(msg); err != nil {
+			log.Printf("Failed to notify owner %d: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Reports rendered as CSV and delivered as Telegram documents
+type ReportPeriod struct {
+	From  time.Time
+	To    time.Time
+	Label string
+}
+
+type reportBuilder func(ctx context.Context, period ReportPeriod) ([][]string, error)
+
+var reportBuilders = map[string]reportBuilder{
+	"new_clients": buildNewClientsReport,
+	"revenue":     buildRevenueReport,
+	"squads":      buildSquadsReport,
+	"nodes":       buildNodesReport,
+}
+
+// snapshotReports show the current state and ignore the period
+var snapshotReports = map[string]bool{"nodes": true}
+
+func buildNewClientsReport(ctx context.Context, period ReportPeriod) ([][]string, error) {
+	users, err := getAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{{"username", "status", "created_at", "expire_at", "traffic_limit_bytes", "telegram_id"}}
+	for _, u := range users {
+		if u.CreatedAt.Before(period.From) || !u.CreatedAt.Before(period.To) {
+			continue
+		}
+		tgID := ""
+		if u.TelegramID != nil {
+			tgID = strconv.FormatInt(*u.TelegramID, 10)
+		}
+		rows = append(rows, []string{
+			u.Username,
+			u.Status,
+			u.CreatedAt.Format(time.RFC3339),
+			u.ExpireAt.Format(time.RFC3339),
+			strconv.FormatInt(u.TrafficLimitBytes, 10),
+			tgID,
+		})
+	}
+	return rows, nil
+}
+
+// buildRevenueReport sums list prices of the plans sold through the bot
+func buildRevenueReport(_ context.Context, period ReportPeriod) ([][]string, error) {
+	type planTotal struct {
+		count    int
+		amount   float64
+		currency string
+	}
+	totals := make(map[string]*planTotal)
+
+	store.view(func(d *storeData) {
+		// Family members are part of the family plan; older records lack the flag
+		members := make(map[string]bool)
+		for _, f := range d.Families {
+			for _, m := range f.Members {
+				members[m.UUID] = true
+			}
+		}
+		for _, c := range d.Clients {
+			if c.Family || members[c.UUID] || c.CreatedAt.Before(period.From) || !c.CreatedAt.Before(period.To) {
+				continue
+			}
+			plan := c.Plan
+			if plan == "" {
+				plan = fmt.Sprintf("%d GB / %d дней", c.TrafficGB, c.Days)
+			}
+			t, ok := totals[plan]
+			if !ok {
+				t = &planTotal{currency: c.Currency}
+				totals[plan] = t
+			}
+			t.count++
+			t.amount += c.Price
+		}
+	})
+
+	plans := make([]string, 0, len(totals))
+	for p := range totals {
+		plans = append(plans, p)
+	}
+	sort.Strings(plans)
+
+	rows := [][]string{{"plan", "clients", "amount", "currency"}}
+	for _, p := range plans {
+		t := totals[p]
+		rows = append(rows, []string{p, strconv.Itoa(t.count), strconv.FormatFloat(t.amount, 'f', 2, 64), t.currency})
+	}
+	return rows, nil
+}
+
+// buildSquadsReport counts users seen online during the period in every squad
+func buildSquadsReport(ctx context.Context, period ReportPeriod) ([][]string, error) {
+	users, err := getAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make(map[string]int)
+	total := make(map[string]int)
+	for _, u := range users {
+		online := u.OnlineAt != nil && !u.OnlineAt.Before(period.From) && u.OnlineAt.Before(period.To)
+		for _, sq := range u.ActiveInternalSquads {
+			total[sq.Name]++
+			if online {
+				active[sq.Name]++
+			}
+		}
+	}
+
+	squads := make([]string, 0, len(total))
+	for name := range total {
+		squads = append(squads, name)
+	}
+	sort.Strings(squads)
+
+	rows := [][]string{{"squad", "active_users", "total_users"}}
+	for _, name := range squads {
+		rows = append(rows, []string{name, strconv.Itoa(active[name]), strconv.Itoa(total[name])})
+	}
+	return rows, nil
+}
+
+// buildNodesReport lists node traffic counters; the panel only keeps totals since the last reset
+func buildNodesReport(ctx context.Context, period ReportPeriod) ([][]string, error) {
+	nodes, err := getNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{{"node", "address", "country", "traffic_used_bytes", "traffic_limit_bytes"}}
+	for _, n := range nodes {
+		rows = append(rows, []string{
+			n.Name,
+			n.Address,
+			n.CountryCode,
+			strconv.FormatInt(n.TrafficUsedBytes, 10),
+			strconv.FormatInt(n.TrafficLimitBytes, 10),
+		})
+	}
+	return rows, nil
+}
+
+// parseReportPeriod understands "week", "month", "Nd" and "YYYY-MM"
+func parseReportPeriod(s string, now time.Time) (ReportPeriod, error) {
+	switch {
+	case s == "" || s == "week":
+		return ReportPeriod{From: now.AddDate(0, 0, -7), To: now, Label: "7d"}, nil
+
+	case s == "month":
+		return ReportPeriod{From: now.AddDate(0, -1, 0), To: now, Label: "30d"}, nil
+
+	case strings.HasSuffix(s, "d"):
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 || days > 366 {
+			return ReportPeriod{}, fmt.Errorf("invalid period %q", s)
+		}
+		return ReportPeriod{From: now.AddDate(0, 0, -days), To: now, Label: s}, nil
+	}
+
+	month, err := time.ParseInLocation("2006-01", s, now.Location())
+	if err != nil {
+		return ReportPeriod{}, fmt.Errorf("invalid period %q", s)
+	}
+	return ReportPeriod{From: month, To: month.AddDate(0, 1, 0), Label: s}, nil
+}
+
+func renderReportCSV(rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func sendReport(ctx context.Context, bot *tgbotapi.BotAPI, chatIDs []int64, name, reportType string, period ReportPeriod) error {
+	rows, err := reportBuilders[reportType](ctx, period)
+	if err != nil {
+		return err
+	}
+
+	data, err := renderReportCSV(rows)
+	if err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("%s_%s.csv", name, period.Label)
+	caption := fmt.Sprintf("📈 Отчёт %s\n%s — %s\nСтрок: %d",
+		name,
+		period.From.Format("02.01.2006"),
+		period.To.Format("02.01.2006"),
+		len(rows)-1,
+	)
+	if snapshotReports[reportType] {
+		now := time.Now()
+		fileName = fmt.Sprintf("%s_%s.csv", name, now.Format("2006-01-02_1504"))
+		caption = fmt.Sprintf("📈 Отчёт %s\nТекущее состояние на %s\nСтрок: %d", name, now.Format("02.01.2006 15:04"), len(rows)-1)
+	}
+
+	for _, chatID := range chatIDs {
+		doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: data})
+		doc.Caption = caption
+		if _, err := bot.Send(doc); err != nil {
+			log.Printf("Failed to send report %s to %d: %v", name, chatID, err)
+		}
+	}
+	return nil
+}
+
+func handleReportCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	args := strings.Fields(msg.CommandArguments())
+
+	if len(args) == 0 {
+		var sb strings.Builder
+		sb.WriteString("📈 *Отчёты*\n\nИспользование: `/report <имя> [week|month|Nd|YYYY-MM]`\n\nДоступные отчёты:\n")
+		for _, r := range cfg.Reports {
+			fmt.Fprintf(&sb, "• `%s` (`%s`)\n", r.Name, r.Type)
+		}
+		types := make([]string, 0, len(reportBuilders))
+		for t := range reportBuilders {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			fmt.Fprintf(&sb, "• `%s`\n", t)
+		}
+		m := tgbotapi.NewMessage(chatID, sb.String())
+		m.ParseMode = "Markdown"
+		bot.Send(m)
+		return
+	}
+
+	// Accept both configured report names and raw report types
+	name, reportType := args[0], args[0]
+	for _, r := range cfg.Reports {
+		if r.Name == args[0] {
+			reportType = r.Type
+			break
+		}
+	}
+	if _, ok := reportBuilders[reportType]; !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Неизвестный отчёт: %s", args[0])))
+		return
+	}
+
+	periodArg := ""
+	if len(args) > 1 {
+		periodArg = args[1]
+	}
+	period, err := parseReportPeriod(periodArg, time.Now())
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Период: week, month, Nd (например 14d) или YYYY-MM."))
+		return
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, "⏳ Формирую отчёт..."))
+	if err := sendReport(ctx, bot, []int64{chatID}, name, reportType, period); err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка формирования отчёта:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+	}
+}
+
+// lastReportDue returns the most recent scheduled time (09:00 local) and the period it covers
+func lastReportDue(schedule string, now time.Time) (time.Time, ReportPeriod) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, now.Location())
+
+	switch schedule {
+	case "weekly":
+		due := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7)) // Monday
+		if due.After(now) {
+			due = due.AddDate(0, 0, -7)
+		}
+		from := time.Date(due.Year(), due.Month(), due.Day()-7, 0, 0, 0, 0, now.Location())
+		to := from.AddDate(0, 0, 7)
+		return due, ReportPeriod{From: from, To: to, Label: from.Format("2006-01-02")}
+
+	default: // monthly
+		due := time.Date(now.Year(), now.Month(), 1, 9, 0, 0, 0, now.Location())
+		if due.After(now) {
+			due = due.AddDate(0, -1, 0)
+		}
+		from := time.Date(due.Year(), due.Month()-1, 1, 0, 0, 0, 0, now.Location())
+		return due, ReportPeriod{From: from, To: from.AddDate(0, 1, 0), Label: from.Format("2006-01")}
+	}
+}
+
+// A failed scheduled report is retried with a growing delay instead of on every tick
+const (
+	reportRetryMin = 5 * time.Minute
+	reportRetryMax = 2 * time.Hour
+)
+
+type reportRetry struct {
+	delay time.Duration
+	next  time.Time
+}
+
+var reportRetries = make(map[string]reportRetry) // only touched by the scheduler goroutine
+
+func runScheduledReports(ctx context.Context, bot *tgbotapi.BotAPI, now time.Time) {
+	if cfg.AdminGroup != nil {
+		ctx = withFeatureTopic(ctx, cfg.AdminGroup.ChatID, "report")
+		bot = tracedBot(ctx, bot)
+	}
+	for _, r := range cfg.Reports {
+		if r.Schedule == "" || len(r.Chats) == 0 {
+			continue
+		}
+
+		due, period := lastReportDue(r.Schedule, now)
+
+		var lastRun time.Time
+		store.view(func(d *storeData) {
+			lastRun = d.ReportRuns[r.Name]
+		})
+
+		// Don't replay a missed report on the very first start
+		if lastRun.IsZero() {
+			if err := store.update(func(d *storeData) { d.ReportRuns[r.Name] = now }); err != nil {
+				log.Printf("Failed to save report run: %v", err)
+			}
+			continue
+		}
+		if !lastRun.Before(due) {
+			continue
+		}
+
+		if retry, ok := reportRetries[r.Name]; ok && now.Before(retry.next) {
+			continue
+		}
+
+		if err := sendReport(ctx, bot, r.Chats, r.Name, r.Type, period); err != nil {
+			retry := reportRetries[r.Name]
+			retry.delay = min(max(retry.delay*2, reportRetryMin), reportRetryMax)
+			retry.next = now.Add(retry.delay)
+			reportRetries[r.Name] = retry
+			log.Printf("Scheduled report %s failed, retrying in %s: %v", r.Name, retry.delay, err)
+			continue
+		}
+		delete(reportRetries, r.Name)
+		if err := store.update(func(d *storeData) { d.ReportRuns[r.Name] = now }); err != nil {
+			log.Printf("Failed to save report run: %v", err)
+		}
+	}
+}
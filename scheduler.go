@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Background jobs checked once a minute, each tick of each job is a separate trace
+var jobs = []struct {
+	name string
+	run  func(ctx context.Context, bot *tgbotapi.BotAPI, now time.Time)
+}{
+	{"reports", runScheduledReports},
+	{"pending_notifications", deliverPendingNotifications},
+	{"usage_notifications", runUsageNotifications},
+	{"freeze_expiry", runFreezeExpiry},
+	{"activations", runScheduledActivations},
+	{"link_expiry", hideExpiredLinks},
+}
+
+func startScheduler(bot *tgbotapi.BotAPI) {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			configMu.RLock()
+			for _, job := range jobs {
+				ctx, span := tracer.Start(context.Background(), "job "+job.name)
+				job.run(ctx, tracedBot(ctx, bot), now)
+				span.End()
+			}
+			configMu.RUnlock()
+		}
+	}()
+}
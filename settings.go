@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Per-user notification settings menu
+func sendSettingsMenu(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) {
+	prefs := prefsFor(userID)
+
+	toggle := func(on bool, title string) string {
+		if on {
+			return "✅ " + title
+		}
+		return "❌ " + title
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(toggle(prefs.ExpiryReminders, "Окончание подписки"), "pref_expiry"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(toggle(prefs.TrafficAlerts, "Расход трафика"), "pref_traffic"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(toggle(prefs.Announcements, "Объявления"), "pref_announce"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌙 Тихие часы: "+prefs.quietText(), "pref_quiet"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌍 Часовой пояс: "+prefs.Timezone, "pref_tz"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "main_menu"),
+		),
+	)
+
+	text := "⚙️ *Настройки уведомлений*\n\nВ тихие часы уведомления откладываются и приходят после их окончания."
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+func handlePrefCallback(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
+	switch data {
+	case "pref_expiry", "pref_traffic", "pref_announce":
+		updatePrefs(userID, func(p *NotifyPrefs) {
+			switch data {
+			case "pref_expiry":
+				p.ExpiryReminders = !p.ExpiryReminders
+			case "pref_traffic":
+				p.TrafficAlerts = !p.TrafficAlerts
+			case "pref_announce":
+				p.Announcements = !p.Announcements
+			}
+		})
+		sendSettingsMenu(ctx, bot, chatID, userID)
+
+	case "pref_quiet":
+		startFlow(ctx, bot, chatID, userID, "quiet_hours", nil)
+
+	case "pref_tz":
+		startFlow(ctx, bot, chatID, userID, "timezone", nil)
+	}
+}
+
+func updatePrefs(userID int64, fn func(p *NotifyPrefs)) {
+	err := store.update(func(d *storeData) {
+		p, ok := d.Preferences[userID]
+		if !ok {
+			defaults := defaultNotifyPrefs()
+			p = &defaults
+			d.Preferences[userID] = p
+		}
+		fn(p)
+	})
+	if err != nil {
+		log.Printf("Failed to save preferences for %d: %v", userID, err)
+	}
+}
+
+// parseQuietHours accepts "23-8" style ranges, "off" disables quiet hours
+func parseQuietHours(text string) (from, to int, err error) {
+	text = strings.TrimSpace(strings.ToLower(text))
+	if text == "off" {
+		return 0, 0, nil
+	}
+
+	fromStr, toStr, ok := strings.Cut(text, "-")
+	var err1, err2 error
+	from, err1 = strconv.Atoi(strings.TrimSpace(fromStr))
+	to, err2 = strconv.Atoi(strings.TrimSpace(toStr))
+	if !ok || err1 != nil || err2 != nil || from < 0 || from > 23 || to < 0 || to > 23 {
+		return 0, 0, fmt.Errorf("неверный формат. Пример: 23-8")
+	}
+	return from, to, nil
+}
+
+func parseTimezone(text string) (string, error) {
+	tz := strings.TrimSpace(text)
+	if _, err := time.LoadLocation(tz); err != nil || tz == "" || tz == "Local" {
+		return "", fmt.Errorf("неизвестный часовой пояс: %s", tz)
+	}
+	return tz, nil
+}
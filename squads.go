@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Internal squad management: listing, create/rename/delete and moving users between squads
+var squadNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{2,30}$`)
+
+func findSquad(ctx context.Context, uuid string) (*InternalSquad, error) {
+	squads, err := getInternalSquads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range squads {
+		if squads[i].UUID == uuid {
+			return &squads[i], nil
+		}
+	}
+	return nil, fmt.Errorf("squad %s not found", uuid)
+}
+
+func inboundTags(inbounds []Inbound) string {
+	if len(inbounds) == 0 {
+		return "—"
+	}
+	tags := make([]string, 0, len(inbounds))
+	for _, inb := range inbounds {
+		tags = append(tags, inb.Tag)
+	}
+	return strings.Join(tags, ", ")
+}
+
+func sendSquadsMenu(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64) {
+	squads, err := getInternalSquads(ctx)
+	if err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка получения сквадов:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("👥 *Сквады*\n\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, sq := range squads {
+		fmt.Fprintf(&sb, "• `%s` — пользователей: *%d*\n  Инбаунды: `%s`\n", sq.Name, sq.Info.MembersCount, inboundTags(sq.Inbounds))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(sq.Name, "squad_view_"+sq.UUID),
+		))
+	}
+	if len(squads) == 0 {
+		sb.WriteString("Сквадов пока нет.")
+	}
+
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ Создать сквад", "squad_create"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Главное меню", "main_menu"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bot.Send(msg)
+}
+
+func handleSquadCallback(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
+	switch {
+	case data == "squads":
+		sendSquadsMenu(ctx, bot, chatID)
+
+	case data == "squad_create":
+		startFlow(ctx, bot, chatID, userID, "squad_create", nil)
+
+	case strings.HasPrefix(data, "squad_view_"):
+		sendSquadView(ctx, bot, chatID, strings.TrimPrefix(data, "squad_view_"))
+
+	case strings.HasPrefix(data, "squad_rename_"):
+		uuid := strings.TrimPrefix(data, "squad_rename_")
+		startFlow(ctx, bot, chatID, userID, "squad_rename", func(s *WizardSession) { s.Values["squad"] = uuid })
+
+	case strings.HasPrefix(data, "squad_move_"):
+		uuid := strings.TrimPrefix(data, "squad_move_")
+		startFlow(ctx, bot, chatID, userID, "squad_move", func(s *WizardSession) { s.Values["squad"] = uuid })
+
+	case strings.HasPrefix(data, "squad_delete_"):
+		squad, err := findSquad(ctx, strings.TrimPrefix(data, "squad_delete_"))
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Сквад не найден."))
+			return
+		}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", "squad_delconf_"+squad.UUID),
+				tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "squad_view_"+squad.UUID),
+			),
+		)
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"Удалить сквад `%s`? Пользователи (%d) потеряют доступ к его инбаундам.",
+			squad.Name, squad.Info.MembersCount,
+		))
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = keyboard
+		bot.Send(msg)
+
+	case strings.HasPrefix(data, "squad_delconf_"):
+		handleSquadDelete(ctx, bot, chatID, userID, strings.TrimPrefix(data, "squad_delconf_"))
+	}
+}
+
+func sendSquadView(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, uuid string) {
+	squad, err := findSquad(ctx, uuid)
+	if err != nil {
+		log.Printf("Failed to get squad %s: %v", uuid, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Сквад не найден."))
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Переименовать", "squad_rename_"+squad.UUID),
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", "squad_delete_"+squad.UUID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔀 Перенести пользователей", "squad_move_"+squad.UUID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "squads"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"👥 *Сквад* `%s`\n\nПользователей: *%d*\nИнбаунды (%d): `%s`",
+		squad.Name, squad.Info.MembersCount, len(squad.Inbounds), inboundTags(squad.Inbounds),
+	))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+func handleSquadDelete(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, uuid string) {
+	squad, err := findSquad(ctx, uuid)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Сквад не найден."))
+		return
+	}
+
+	if err := deleteInternalSquad(ctx, uuid); err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка удаления сквада:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+	recordAudit(userID, "squad_delete", squad.Name, "")
+
+	bot.Send(tgbotapi.NewMessage(chatID, "🗑 Сквад удалён."))
+	sendSquadsMenu(ctx, bot, chatID)
+}
+
+// parseInboundSelection maps a comma-separated list of tags to inbound UUIDs, "all" selects every inbound
+func parseInboundSelection(text string, inbounds []Inbound) ([]string, error) {
+	text = strings.TrimSpace(text)
+	if text == "-" {
+		return []string{}, nil
+	}
+
+	byTag := make(map[string]string, len(inbounds))
+	all := make([]string, 0, len(inbounds))
+	for _, inb := range inbounds {
+		byTag[strings.ToLower(inb.Tag)] = inb.UUID
+		all = append(all, inb.UUID)
+	}
+	if strings.EqualFold(text, "all") {
+		return all, nil
+	}
+
+	var uuids, unknown []string
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		tag := strings.TrimSpace(part)
+		if tag == "" {
+			continue
+		}
+		uuid, ok := byTag[strings.ToLower(tag)]
+		if !ok {
+			unknown = append(unknown, tag)
+			continue
+		}
+		uuids = append(uuids, uuid)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("неизвестные инбаунды: %s", strings.Join(unknown, ", "))
+	}
+	if len(uuids) == 0 {
+		return nil, fmt.Errorf("укажите хотя бы один инбаунд, all или -")
+	}
+	return uuids, nil
+}
+
+func finishSquadCreate(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+	var inbounds []string
+	if v := s.Values["inbounds"]; v != "" {
+		inbounds = strings.Split(v, ",")
+	}
+
+	squad, err := createInternalSquad(ctx, s.Values["name"], inbounds)
+	if err != nil {
+		errMsg := tgbotapi.NewMessage(s.ChatID, fmt.Sprintf("❌ Ошибка создания сквада:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+	recordAudit(s.UserID, "squad_create", squad.Name, fmt.Sprintf("%d inbounds", len(inbounds)))
+
+	sendSquadView(ctx, bot, s.ChatID, squad.UUID)
+}
+
+func finishSquadRename(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+	uuid := s.Values["squad"]
+	old, err := findSquad(ctx, uuid)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(s.ChatID, "❌ Сквад не найден."))
+		return
+	}
+
+	if _, err := renameInternalSquad(ctx, uuid, s.Values["name"]); err != nil {
+		errMsg := tgbotapi.NewMessage(s.ChatID, fmt.Sprintf("❌ Ошибка переименования:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+	recordAudit(s.UserID, "squad_rename", old.Name, "→ "+s.Values["name"])
+
+	sendSquadView(ctx, bot, s.ChatID, uuid)
+}
+
+// leaseSquadMove takes the leases on the chosen users, so the move applies to them as they
+// were when chosen. Leases from an earlier answer to the same step are released first.
+func leaseSquadMove(s *WizardSession, names []string) error {
+	releaseSquadMove(s)
+	for _, name := range names {
+		user, err := getUserByUsername(s.ctx, name)
+		if err != nil {
+			continue // reported as not found when the move is applied
+		}
+		if err := beginEdit(user, s.UserID); err != nil {
+			releaseSquadMove(s)
+			return err
+		}
+		s.Values["lease:"+name] = user.UUID
+	}
+	return nil
+}
+
+func releaseSquadMove(s *WizardSession) {
+	for key, uuid := range s.Values {
+		if strings.HasPrefix(key, "lease:") {
+			endEdit(uuid, s.UserID)
+			delete(s.Values, key)
+		}
+	}
+}
+
+func cancelSquadMove(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+	releaseSquadMove(s)
+	cancelToSquad(ctx, bot, s)
+}
+
+// finishSquadMove takes the selected users out of the source squad and puts them into the target one
+func finishSquadMove(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+	from, to := s.Values["squad"], s.Values["to"]
+	target, err := findSquad(ctx, to)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(s.ChatID, "❌ Сквад не найден."))
+		return
+	}
+
+	var sb strings.Builder
+	moved := 0
+	for _, name := range s.Names {
+		user, err := getUserByUsername(ctx, name)
+		if err != nil {
+			fmt.Fprintf(&sb, "❌ `%s` — не найден\n", name)
+			continue
+		}
+
+		// The selection may be stale, another admin could have moved the user meanwhile
+		inSource := false
+		squads := []string{to}
+		for _, sq := range user.ActiveInternalSquads {
+			if sq.UUID == from {
+				inSource = true
+			}
+			if sq.UUID != from && sq.UUID != to {
+				squads = append(squads, sq.UUID)
+			}
+		}
+		if !inSource {
+			fmt.Fprintf(&sb, "⚠️ `%s` — уже не в скваде\n", name)
+			continue
+		}
+		err = finishEdit(ctx, user, s.UserID, func() error {
+			_, err := updateRemnawaveUser(ctx, UpdateUserRequest{UUID: user.UUID, ActiveInternalSquads: squads})
+			return err
+		})
+		if err != nil {
+			fmt.Fprintf(&sb, "❌ `%s` — `%s`\n", name, err.Error())
+			continue
+		}
+		recordAudit(s.UserID, "squad_move", user.Username, "→ "+target.Name)
+		moved++
+		fmt.Fprintf(&sb, "✅ `%s`\n", user.Username)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ К сквадам", "squads"),
+		),
+	)
+	msg := tgbotapi.NewMessage(s.ChatID, fmt.Sprintf("🔀 *Перенесено в* `%s`*: %d из %d*\n\n%s", target.Name, moved, len(s.Names), sb.String()))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+// squadTargetChoices lists the squads users can be moved to
+func squadTargetChoices(s *WizardSession) [][]Choice {
+	squads, err := getInternalSquads(s.ctx)
+	if err != nil {
+		log.Printf("Failed to get squads: %v", err)
+		return nil
+	}
+
+	var choices [][]Choice
+	for _, sq := range squads {
+		if sq.UUID != s.Values["squad"] {
+			choices = append(choices, []Choice{{sq.Name, sq.UUID}})
+		}
+	}
+	return choices
+}
+
+func squadNamePrompt(*WizardSession) string {
+	return "✏️ *Введите название сквада:*\n\nЛатиница, цифры, дефис и подчёркивание, от 2 до 30 символов."
+}
+
+func applySquadName(s *WizardSession, in WizardInput) error {
+	name := strings.TrimSpace(in.Value)
+	if !squadNameRe.MatchString(name) {
+		return fmt.Errorf("название должно состоять из 2–30 латинских букв, цифр, дефисов или подчёркиваний")
+	}
+	s.Values["name"] = name
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Local bot data persisted between restarts
+type BotUser struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username,omitempty"`
+	FirstName string    `json:"firstName,omitempty"`
+	LastName  string    `json:"lastName,omitempty"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// ClientRecord remembers what was sold when a client was created through the bot
+type ClientRecord struct {
+	UUID      string    `json:"uuid"`
+	Username  string    `json:"username"`
+	CreatedBy int64     `json:"createdBy"`
+	TrafficGB int       `json:"trafficGb"`
+	Days      int       `json:"days"`
+	Plan      string    `json:"plan,omitempty"`
+	Price     float64   `json:"price,omitempty"`
+	Currency  string    `json:"currency,omitempty"`
+	Family    bool      `json:"family,omitempty"` // family member, paid for with the family plan
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type storeData struct {
+	BotUsers   map[int64]*BotUser       `json:"botUsers"`
+	Clients    map[string]*ClientRecord `json:"clients"`
+	ReportRuns map[string]time.Time     `json:"reportRuns"`
+
+	Preferences          map[int64]*NotifyPrefs `json:"preferences"`
+	PendingNotifications []*PendingNotification `json:"pendingNotifications"`
+	ExpiryReminded       map[string]time.Time   `json:"expiryReminded"`
+	TrafficAlerted       map[string]bool        `json:"trafficAlerted"`
+	UsageNotifiedAt      time.Time              `json:"usageNotifiedAt"`
+
+	Freezes            map[string]*FreezeState       `json:"freezes"`
+	PendingActivations map[string]*PendingActivation `json:"pendingActivations"`
+	Families           map[int64]*Family             `json:"families"`
+	Wizards            map[string]*WizardSession     `json:"wizards"` // keyed by "chatID:userID"
+	AuditLog           []*AuditEntry                 `json:"auditLog"`
+	LinkMessages       []*LinkMessage                `json:"linkMessages"` // links waiting to be hidden
+}
+
+type Store struct {
+	mu   sync.Mutex
+	path string
+	keys *StoreKeys // nil keeps the file in plain JSON
+	data storeData
+}
+
+func openStore(path string, keys *StoreKeys) (*Store, error) {
+	s := &Store{path: path, keys: keys}
+
+	raw, reseal, err := readStoreFile(path, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.data.BotUsers == nil {
+		s.data.BotUsers = make(map[int64]*BotUser)
+	}
+	if s.data.Clients == nil {
+		s.data.Clients = make(map[string]*ClientRecord)
+	}
+	if s.data.ReportRuns == nil {
+		s.data.ReportRuns = make(map[string]time.Time)
+	}
+	if s.data.Preferences == nil {
+		s.data.Preferences = make(map[int64]*NotifyPrefs)
+	}
+	if s.data.ExpiryReminded == nil {
+		s.data.ExpiryReminded = make(map[string]time.Time)
+	}
+	if s.data.TrafficAlerted == nil {
+		s.data.TrafficAlerted = make(map[string]bool)
+	}
+	if s.data.Freezes == nil {
+		s.data.Freezes = make(map[string]*FreezeState)
+	}
+	if s.data.PendingActivations == nil {
+		s.data.PendingActivations = make(map[string]*PendingActivation)
+	}
+	if s.data.Families == nil {
+		s.data.Families = make(map[int64]*Family)
+	}
+	if s.data.Wizards == nil {
+		s.data.Wizards = make(map[string]*WizardSession)
+	}
+
+	if reseal {
+		if err := s.save(); err != nil {
+			return nil, fmt.Errorf("failed to re-encrypt data file: %w", err)
+		}
+		log.Printf("Data file re-encrypted with the current key")
+	}
+	return s, nil
+}
+
+// readStoreFile returns the decrypted contents of the data file, empty if it doesn't exist yet
+func readStoreFile(path string, keys *StoreKeys) (plain []byte, reseal bool, err error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return keys.open(raw)
+}
+
+// view gives read access to the data under the store lock
+func (s *Store) view(fn func(d *storeData)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.data)
+}
+
+// update modifies the data under the store lock and writes it to disk
+func (s *Store) update(fn func(d *storeData)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.data)
+	return s.save()
+}
+
+func (s *Store) save() error {
+	raw, err := json.MarshalIndent(&s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if s.keys != nil {
+		if raw, err = s.keys.seal(raw); err != nil {
+			return err
+		}
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated store
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// rememberBotUser records everyone who talks to the bot so panel users can be matched to them later
+func rememberBotUser(u *tgbotapi.User) {
+	if u == nil {
+		return
+	}
+
+	changed := false
+	store.view(func(d *storeData) {
+		known, ok := d.BotUsers[u.ID]
+		changed = !ok ||
+			known.Username != u.UserName ||
+			known.FirstName != u.FirstName ||
+			known.LastName != u.LastName ||
+			time.Since(known.LastSeen) > time.Hour
+	})
+	if !changed {
+		return
+	}
+
+	err := store.update(func(d *storeData) {
+		d.BotUsers[u.ID] = &BotUser{
+			ID:        u.ID,
+			Username:  u.UserName,
+			FirstName: u.FirstName,
+			LastName:  u.LastName,
+			LastSeen:  time.Now(),
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to save bot user %d: %v", u.ID, err)
+	}
+}
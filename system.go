@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sendSystemStats shows panel health for quick triage from Telegram
+func sendSystemStats(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64) {
+	stats, err := getSystemStats(ctx)
+	if err != nil {
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка получения статистики:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+
+	// Bandwidth is secondary, show the rest even if it fails
+	today, month := "—", "—"
+	if bw, err := getBandwidthStats(ctx); err != nil {
+		log.Printf("Failed to get bandwidth stats: %v", err)
+	} else {
+		today, month = bw.LastTwoDays.Current, bw.CalendarMonth.Current
+	}
+
+	memPercent := 0.0
+	if stats.Memory.Total > 0 {
+		memPercent = float64(stats.Memory.Used) / float64(stats.Memory.Total) * 100
+	}
+
+	text := fmt.Sprintf(
+		"🖥 *Состояние панели*\n\n"+
+			"🧠 CPU: *%d ядер* (%d физ.)\n"+
+			"💾 Память: *%s из %s* (%.0f%%)\n"+
+			"⏱ Аптайм: *%s*\n\n"+
+			"👥 Онлайн: *%d* из %d пользователей\n"+
+			"📆 За сутки: *%d*\n"+
+			"🛰 Нод онлайн: *%d*\n\n"+
+			"📊 Трафик сегодня: *%s*\n"+
+			"📊 Трафик за месяц: *%s*\n\n"+
+			"🕒 Обновлено: %s",
+		stats.CPU.Cores, stats.CPU.PhysicalCores,
+		formatBytes(stats.Memory.Used), formatBytes(stats.Memory.Total), memPercent,
+		formatUptime(stats.Uptime),
+		stats.OnlineStats.OnlineNow, stats.Users.TotalUsers,
+		stats.OnlineStats.LastDay,
+		stats.Nodes.TotalOnline,
+		today, month,
+		time.Now().Format("15:04:05"),
+	)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Обновить", "system"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Главное меню", "main_menu"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
+
+func formatUptime(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if days > 0 {
+		return fmt.Sprintf("%d д %d ч", days, hours)
+	}
+	return fmt.Sprintf("%d ч %d мин", hours, int(d.Minutes())%60)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing of updates, handlers, Telegram sends and panel calls.
+// TRACE_EXPORTER selects the exporter: empty (off), stdout or otlp; the OTLP endpoint
+// comes from the standard OTEL_EXPORTER_OTLP_ENDPOINT, a local collector by default.
+var tracer = otel.Tracer("botik")
+
+func initTracing(ctx context.Context) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch os.Getenv("TRACE_EXPORTER") {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown TRACE_EXPORTER %q, expected stdout or otlp", os.Getenv("TRACE_EXPORTER"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "botik"))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// traceID returns the current trace ID, empty when tracing is off
+func traceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// withTraceID marks an error with the trace ID so admins can quote it when reporting problems
+func withTraceID(ctx context.Context, err error) error {
+	id := traceID(ctx)
+	if id == "" {
+		return err
+	}
+	return fmt.Errorf("%w (trace %s)", err, id)
+}
+
+func failSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// tracedBot returns a copy of the bot whose API calls become child spans of ctx
+func tracedBot(ctx context.Context, bot *tgbotapi.BotAPI) *tgbotapi.BotAPI {
+	traced := *bot
+	traced.Client = &http.Client{Transport: telegramTransport{ctx: ctx}}
+	return &traced
+}
+
+type telegramTransport struct {
+	ctx context.Context
+}
+
+func (t telegramTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The path is /bot<token>/<method>, only the method may end up in spans
+	method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+
+	ctx, span := tracer.Start(t.ctx, "telegram "+method, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	if err := addThread(t.ctx, req, method); err != nil {
+		failSpan(span, err)
+		return nil, err
+	}
+	if err := makeReadOnly(t.ctx, req, method); err != nil {
+		failSpan(span, err)
+		return nil, err
+	}
+
+	// Transport errors don't carry the URL, unlike http.Client ones, so they are safe to record
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		failSpan(span, err)
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
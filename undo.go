@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Undoing a mistyped client creation shortly after it happened
+const defaultUndoMinutes = 10
+
+type RecentCreation struct {
+	User      *RemnawaveUser // as created, to refuse undoing a client edited since
+	Params    ClientParams
+	CreatedBy int64
+	CreatedAt time.Time
+}
+
+var (
+	recentCreations   = make(map[string]RecentCreation)
+	recentCreationsMu sync.Mutex
+)
+
+func undoWindow() time.Duration {
+	if cfg.UndoMinutes > 0 {
+		return time.Duration(cfg.UndoMinutes) * time.Minute
+	}
+	return defaultUndoMinutes * time.Minute
+}
+
+// rememberCreation keeps the wizard choices of a new client while it can still be undone
+func rememberCreation(user *RemnawaveUser, params ClientParams, createdBy int64) {
+	recentCreationsMu.Lock()
+	defer recentCreationsMu.Unlock()
+
+	for uuid, c := range recentCreations {
+		if time.Since(c.CreatedAt) > undoWindow() {
+			delete(recentCreations, uuid)
+		}
+	}
+	recentCreations[user.UUID] = RecentCreation{
+		User:      user,
+		Params:    params,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+}
+
+func handleUndo(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, uuid string) {
+	recentCreationsMu.Lock()
+	c, ok := recentCreations[uuid]
+	if ok && time.Since(c.CreatedAt) <= undoWindow() && (c.CreatedBy == userID || isOwner(userID)) {
+		delete(recentCreations, uuid)
+	} else {
+		ok = false
+	}
+	recentCreationsMu.Unlock()
+
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⌛ Отменить создание можно только в течение %d мин.", int(undoWindow().Minutes()))))
+		return
+	}
+
+	// Only the client as created may be undone, not one edited since
+	if err := applyEdit(ctx, c.User, clientVersion(c.User), userID, func() error { return deleteRemnawaveUser(ctx, uuid) }); err != nil {
+		// Keep the entry, the undo can be retried within the window
+		recentCreationsMu.Lock()
+		recentCreations[uuid] = c
+		recentCreationsMu.Unlock()
+
+		errMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка удаления клиента:\n`%s`", err.Error()))
+		errMsg.ParseMode = "Markdown"
+		bot.Send(errMsg)
+		return
+	}
+
+	err := store.update(func(d *storeData) {
+		delete(d.Clients, uuid)
+		delete(d.PendingActivations, uuid)
+	})
+	if err != nil {
+		log.Printf("Failed to forget undone client %s: %v", c.User.Username, err)
+	}
+	recordAudit(userID, "undo_create", c.User.Username, fmt.Sprintf("%s, %d days", trafficText(c.Params.TrafficGB), c.Params.Days))
+	log.Printf("User %d undid creation of %s", userID, c.User.Username)
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("↩️ Клиент `%s` удалён. Исправьте параметры:", c.User.Username))
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+
+	startFlow(ctx, bot, chatID, userID, "create_client", func(s *WizardSession) {
+		s.Params = c.Params
+		s.Values["traffic"] = strconv.Itoa(c.Params.TrafficGB)
+		s.Values["expire"] = strconv.Itoa(c.Params.Days)
+		s.Values["start"] = "now"
+		if c.Params.scheduled() {
+			s.Values["start"] = "custom"
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+// The last fully processed update is kept in a small file next to the data file, and polling
+// resumes after it. Telegram picks update IDs at random after a week without updates, so an
+// offset older than that is dropped and Telegram's own confirmation decides where to start.
+// A bounded window of handled IDs guards against replays the offset can't catch.
+const (
+	maxHandledUpdates = 1000
+	updateIDsReset    = 7 * 24 * time.Hour
+)
+
+type HandledUpdates struct {
+	BotID  int64     `json:"botId"` // another bot has its own ID sequence
+	LastID int       `json:"lastId"`
+	LastAt time.Time `json:"lastAt"`
+	IDs    []int     `json:"ids"`
+
+	path string
+	seen map[int]bool
+}
+
+func openHandledUpdates(path string, botID int64) *HandledUpdates {
+	h := &HandledUpdates{path: path, seen: make(map[int]bool)}
+
+	raw, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to read handled updates, starting empty: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(raw, h); err != nil {
+			log.Printf("Failed to parse handled updates, starting empty: %v", err)
+		}
+	}
+	if h.BotID != botID {
+		h.BotID, h.LastID, h.LastAt, h.IDs = botID, 0, time.Time{}, nil
+	}
+	for _, id := range h.IDs {
+		h.seen[id] = true
+	}
+	return h
+}
+
+// offset is where polling resumes, zero leaves it to Telegram
+func (h *HandledUpdates) offset() int {
+	if h.LastID == 0 || time.Since(h.LastAt) > updateIDsReset {
+		return 0
+	}
+	return h.LastID + 1
+}
+
+func (h *HandledUpdates) has(id int) bool {
+	return h.seen[id]
+}
+
+// add is called after every update, so a crash replays at most the one in progress
+func (h *HandledUpdates) add(id int) {
+	h.LastID, h.LastAt = id, time.Now()
+	h.IDs = append(h.IDs, id)
+	h.seen[id] = true
+	if len(h.IDs) > maxHandledUpdates {
+		for _, old := range h.IDs[:len(h.IDs)-maxHandledUpdates] {
+			delete(h.seen, old)
+		}
+		h.IDs = h.IDs[len(h.IDs)-maxHandledUpdates:]
+	}
+
+	raw, err := json.Marshal(h)
+	if err == nil {
+		tmp := h.path + ".tmp"
+		if err = os.WriteFile(tmp, raw, 0600); err == nil {
+			err = os.Rename(tmp, h.path)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to save handled update %d: %v", id, err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// "View as user" for support: the customer screens are rendered into the support chat by
+// the usual handlers, with buttons made inert and subscription links hidden on the way out
+
+type readOnlyKey struct{}
+
+func withReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+func runViewAsCommand(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	targetID, err := strconv.ParseInt(strings.TrimSpace(msg.CommandArguments()), 10, 64)
+	if err != nil || targetID <= 0 {
+		m := tgbotapi.NewMessage(chatID, "Использование: `/viewas <tg_id>`")
+		m.ParseMode = "Markdown"
+		bot.Send(m)
+		return
+	}
+
+	recordAudit(msg.From.ID, "view_as", strconv.FormatInt(targetID, 10), "")
+	log.Printf("User %d views the bot as %d", msg.From.ID, targetID)
+
+	header := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"👁 *Просмотр как* `%d`\n\nНиже то, что видит пользователь. Кнопки не работают, ссылки скрыты, пользователю ничего не отправляется.",
+		targetID,
+	))
+	header.ParseMode = "Markdown"
+	bot.Send(header)
+
+	// Every screen is addressed to the support chat, the customer's ID only selects the data
+	ctx = withReadOnly(ctx)
+	view := tracedBot(ctx, bot)
+	sendMainMenu(ctx, view, chatID)
+	handleMySubs(ctx, view, chatID, targetID)
+	sendSettingsMenu(ctx, view, chatID, targetID)
+	if family := familyOf(targetID); family != nil {
+		sendFamilyView(ctx, view, chatID, family)
+	}
+}
+
+// makeReadOnly rewrites outgoing messages in view mode: callback buttons answer with a
+// notice instead of acting on behalf of the support user, and links are cut out
+func makeReadOnly(ctx context.Context, req *http.Request, method string) error {
+	if ctx.Value(readOnlyKey{}) == nil || req.Body == nil {
+		return nil
+	}
+	if !strings.HasPrefix(method, "send") && !strings.HasPrefix(method, "edit") {
+		return nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType != "application/x-www-form-urlencoded" {
+		return nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return err
+	}
+
+	if text := values.Get("text"); text != "" {
+		values.Set("text", redactLinks(text))
+	}
+	if markup := values.Get("reply_markup"); markup != "" {
+		var keyboard tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal([]byte(markup), &keyboard); err == nil && len(keyboard.InlineKeyboard) > 0 {
+			for _, row := range keyboard.InlineKeyboard {
+				for i := range row {
+					if row[i].CallbackData != nil {
+						inert := "viewas_ro"
+						row[i].CallbackData = &inert
+					}
+				}
+			}
+			raw, _ := json.Marshal(keyboard)
+			values.Set("reply_markup", string(raw))
+		}
+	}
+
+	encoded := values.Encode()
+	req.Body = io.NopCloser(bytes.NewReader([]byte(encoded)))
+	req.ContentLength = int64(len(encoded))
+	return nil
+}
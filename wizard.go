@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Declarative conversation engine: a flow is a set of named steps, each with
+// a prompt, an expected input type, a validator and a transition to the next step
+type InputType string
+
+const (
+	InputButtons  InputType = "buttons"
+	InputText     InputType = "text"
+	InputContact  InputType = "contact"
+	InputDocument InputType = "document"
+)
+
+const (
+	wizardBackText   = "⬅️ Назад"
+	wizardCancelText = "✖️ Отмена"
+)
+
+type Choice struct {
+	Label string
+	Value string
+}
+
+type WizardInput struct {
+	Value    string // pressed button value or message text
+	Contact  *tgbotapi.Contact
+	Document *tgbotapi.Document
+}
+
+type WizardStep struct {
+	Input   InputType
+	Prompt  func(s *WizardSession) string
+	Choices func(s *WizardSession) [][]Choice // InputButtons only
+	Chosen  func(s *WizardSession) string     // optional, value of the choice to mark as current
+	Skip    func(s *WizardSession) bool       // optional, skipped steps don't appear in back history
+	Apply   func(s *WizardSession, in WizardInput) error
+	Next    func(s *WizardSession) string // empty string finishes the flow
+}
+
+type Flow struct {
+	Start  string
+	Steps  map[string]*WizardStep
+	Access func(userID int64) bool
+	Finish func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession)
+	Cancel func(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) // optional, main menu by default
+}
+
+// WizardSession is the persisted progress of one user through one flow in one chat
+type WizardSession struct {
+	Flow     string             `json:"flow"`
+	Step     string             `json:"step"`
+	History  []string           `json:"history,omitempty"`
+	ChatID   int64              `json:"chatId"`
+	UserID   int64              `json:"userId"`
+	Params   ClientParams       `json:"params"`
+	Names    []string           `json:"names,omitempty"`
+	Values   map[string]string  `json:"values,omitempty"`
+	Contact  *tgbotapi.Contact  `json:"contact,omitempty"`
+	Document *tgbotapi.Document `json:"document,omitempty"`
+
+	ctx context.Context // of the update being handled, for step callbacks calling the panel
+}
+
+// flows is filled in flows.go
+var flows map[string]*Flow
+
+// goTo is a transition to a fixed step
+func goTo(step string) func(s *WizardSession) string {
+	return func(*WizardSession) string { return step }
+}
+
+func sessionKey(chatID, userID int64) string {
+	return fmt.Sprintf("%d:%d", chatID, userID)
+}
+
+func getSession(ctx context.Context, chatID, userID int64) *WizardSession {
+	var session *WizardSession
+	store.view(func(d *storeData) {
+		if s, ok := d.Wizards[sessionKey(chatID, userID)]; ok {
+			copied := *s
+			copied.ctx = ctx
+			copied.History = append([]string(nil), s.History...)
+			copied.Values = make(map[string]string, len(s.Values))
+			for k, v := range s.Values {
+				copied.Values[k] = v
+			}
+			session = &copied
+		}
+	})
+	return session
+}
+
+func saveSession(s *WizardSession) {
+	if err := store.update(func(d *storeData) { d.Wizards[sessionKey(s.ChatID, s.UserID)] = s }); err != nil {
+		log.Printf("Failed to save wizard session: %v", err)
+	}
+}
+
+func deleteSession(chatID, userID int64) {
+	if err := store.update(func(d *storeData) { delete(d.Wizards, sessionKey(chatID, userID)) }); err != nil {
+		log.Printf("Failed to delete wizard session: %v", err)
+	}
+}
+
+// startFlow begins a flow, replacing whatever the user was doing in this chat
+func startFlow(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, name string, init func(s *WizardSession)) {
+	flow, ok := flows[name]
+	if !ok {
+		log.Printf("Unknown flow %q", name)
+		return
+	}
+	if flow.Access != nil && !flow.Access(userID) {
+		bot.Send(tgbotapi.NewMessage(chatID, "⛔ У вас нет доступа."))
+		return
+	}
+
+	s := &WizardSession{Flow: name, ChatID: chatID, UserID: userID, Values: make(map[string]string), ctx: ctx}
+	if init != nil {
+		init(s)
+	}
+	enterStep(ctx, bot, s, flow.Start)
+}
+
+// sessionFlow returns the flow of a saved session, false when the session names a flow or
+// step that no longer exists: sessions are kept on disk and outlive renames between releases
+func sessionFlow(s *WizardSession) (*Flow, bool) {
+	flow, ok := flows[s.Flow]
+	if !ok {
+		return nil, false
+	}
+	for _, step := range append([]string{s.Step}, s.History...) {
+		if _, ok := flow.Steps[step]; !ok {
+			return nil, false
+		}
+	}
+	return flow, true
+}
+
+// dropStaleSession forgets a session that can't be continued and asks to start over
+func dropStaleSession(bot *tgbotapi.BotAPI, s *WizardSession) {
+	log.Printf("Dropping wizard session %s: flow %q step %q no longer exists", sessionKey(s.ChatID, s.UserID), s.Flow, s.Step)
+	deleteSession(s.ChatID, s.UserID)
+	bot.Send(tgbotapi.NewMessage(s.ChatID, "⚠️ Бот обновился, и начатое действие больше недоступно. Начните его заново."))
+}
+
+func enterStep(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession, step string) {
+	flow := flows[s.Flow]
+	for step != "" {
+		st, ok := flow.Steps[step]
+		if !ok {
+			log.Printf("Flow %q has no step %q", s.Flow, step)
+			dropStaleSession(bot, s)
+			return
+		}
+		if st.Skip == nil || !st.Skip(s) {
+			break
+		}
+		step = st.Next(s)
+	}
+
+	if step == "" {
+		deleteSession(s.ChatID, s.UserID)
+		flow.Finish(ctx, bot, s)
+		return
+	}
+
+	s.Step = step
+	saveSession(s)
+	sendStepPrompt(ctx, bot, s, "")
+}
+
+func sendStepPrompt(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession, errText string) {
+	st := flows[s.Flow].Steps[s.Step]
+
+	text := st.Prompt(s)
+	if errText != "" {
+		text = "❌ " + tgbotapi.EscapeText(tgbotapi.ModeMarkdown, errText) + "\n\n" + text
+	}
+
+	msg := tgbotapi.NewMessage(s.ChatID, text)
+	msg.ParseMode = "Markdown"
+
+	if st.Input == InputContact {
+		// Contacts can only be requested with a reply keyboard
+		msg.ReplyMarkup = tgbotapi.NewOneTimeReplyKeyboard(
+			tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButtonContact("📱 Отправить контакт")),
+			tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(wizardBackText), tgbotapi.NewKeyboardButton(wizardCancelText)),
+		)
+		bot.Send(msg)
+		return
+	}
+
+	chosen, hasChosen := "", false
+	if st.Chosen != nil {
+		chosen = st.Chosen(s)
+		hasChosen = chosen != ""
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if st.Choices != nil {
+		for _, choiceRow := range st.Choices(s) {
+			var row []tgbotapi.InlineKeyboardButton
+			for _, c := range choiceRow {
+				label := c.Label
+				if hasChosen && c.Value == chosen {
+					label = "✅ " + label
+				}
+				row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, "wz:"+s.Step+":"+c.Value))
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	nav := tgbotapi.NewInlineKeyboardRow()
+	if len(s.History) > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(wizardBackText, "wz:back"))
+	}
+	nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(wizardCancelText, "wz:cancel"))
+	rows = append(rows, nav)
+
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bot.Send(msg)
+}
+
+func applyInput(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession, in WizardInput) {
+	st := flows[s.Flow].Steps[s.Step]
+
+	if st.Apply != nil {
+		if err := st.Apply(s, in); err != nil {
+			saveSession(s)
+			sendStepPrompt(ctx, bot, s, err.Error())
+			return
+		}
+	}
+
+	if st.Input == InputContact {
+		remove := tgbotapi.NewMessage(s.ChatID, "✅ Контакт получен")
+		remove.ReplyMarkup = tgbotapi.NewRemoveKeyboard(false)
+		bot.Send(remove)
+	}
+
+	s.History = append(s.History, s.Step)
+	enterStep(ctx, bot, s, st.Next(s))
+}
+
+func wizardBack(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+	if len(s.History) == 0 {
+		wizardCancel(ctx, bot, s)
+		return
+	}
+
+	s.Step = s.History[len(s.History)-1]
+	s.History = s.History[:len(s.History)-1]
+	saveSession(s)
+	sendStepPrompt(ctx, bot, s, "")
+}
+
+func wizardCancel(ctx context.Context, bot *tgbotapi.BotAPI, s *WizardSession) {
+	deleteSession(s.ChatID, s.UserID)
+
+	if flow, ok := flows[s.Flow]; ok && flow.Cancel != nil {
+		flow.Cancel(ctx, bot, s)
+		return
+	}
+	sendMainMenu(ctx, bot, s.ChatID)
+}
+
+// handleWizardCallback handles "wz:back", "wz:cancel" and "wz:<step>:<value>" buttons
+func handleWizardCallback(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64, data string) {
+	s := getSession(ctx, chatID, userID)
+	if s == nil {
+		sendMainMenu(ctx, bot, chatID)
+		return
+	}
+	flow, ok := sessionFlow(s)
+	if !ok {
+		dropStaleSession(bot, s)
+		return
+	}
+	if flow.Access != nil && !flow.Access(userID) {
+		deleteSession(chatID, userID)
+		bot.Send(tgbotapi.NewMessage(chatID, "⛔ У вас нет доступа."))
+		return
+	}
+
+	switch data {
+	case "wz:back":
+		wizardBack(ctx, bot, s)
+		return
+	case "wz:cancel":
+		wizardCancel(ctx, bot, s)
+		return
+	}
+
+	step, value, ok := strings.Cut(strings.TrimPrefix(data, "wz:"), ":")
+	st := flow.Steps[s.Step]
+	if !ok || step != s.Step || st.Input != InputButtons {
+		return // a button from an earlier prompt
+	}
+
+	valid := false
+	for _, row := range st.Choices(s) {
+		for _, c := range row {
+			valid = valid || c.Value == value
+		}
+	}
+	if !valid {
+		return
+	}
+
+	applyInput(ctx, bot, s, WizardInput{Value: value})
+}
+
+// handleWizardMessage feeds a message into the active flow, reporting whether there was one
+func handleWizardMessage(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) bool {
+	s := getSession(ctx, msg.Chat.ID, msg.From.ID)
+	if s == nil {
+		return false
+	}
+	flow, ok := sessionFlow(s)
+	if !ok {
+		dropStaleSession(bot, s)
+		return true
+	}
+	if flow.Access != nil && !flow.Access(msg.From.ID) {
+		deleteSession(msg.Chat.ID, msg.From.ID)
+		return false
+	}
+
+	switch strings.TrimSpace(msg.Text) {
+	case wizardBackText:
+		wizardBack(ctx, bot, s)
+		return true
+	case wizardCancelText:
+		wizardCancel(ctx, bot, s)
+		return true
+	}
+
+	st := flow.Steps[s.Step]
+	switch st.Input {
+	case InputButtons:
+		sendStepPrompt(ctx, bot, s, "Выберите вариант кнопкой ниже.")
+	case InputText:
+		if strings.TrimSpace(msg.Text) == "" {
+			sendStepPrompt(ctx, bot, s, "Ожидается текстовое сообщение.")
+			return true
+		}
+		applyInput(ctx, bot, s, WizardInput{Value: msg.Text})
+	case InputContact:
+		if msg.Contact == nil {
+			sendStepPrompt(ctx, bot, s, "Нажмите кнопку «Отправить контакт».")
+			return true
+		}
+		applyInput(ctx, bot, s, WizardInput{Contact: msg.Contact})
+	case InputDocument:
+		if msg.Document == nil {
+			sendStepPrompt(ctx, bot, s, "Ожидается файл.")
+			return true
+		}
+		applyInput(ctx, bot, s, WizardInput{Document: msg.Document})
+	}
+	return true
+}
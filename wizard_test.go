@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeTelegram answers every Bot API call with success and records the texts sent
+type fakeTelegram struct {
+	texts []string
+}
+
+func (f *fakeTelegram) Do(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body, _ := io.ReadAll(req.Body)
+		if values, err := url.ParseQuery(string(body)); err == nil && values.Get("text") != "" {
+			f.texts = append(f.texts, values.Get("text"))
+		}
+	}
+	result := `{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot","message_id":1,"chat":{"id":1}}}`
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(result)), Header: make(http.Header)}, nil
+}
+
+func testBot(t *testing.T) (*tgbotapi.BotAPI, *fakeTelegram) {
+	t.Helper()
+	fake := &fakeTelegram{}
+	bot, err := tgbotapi.NewBotAPIWithClient("test", tgbotapi.APIEndpoint, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bot, fake
+}
+
+func testStore(t *testing.T) {
+	t.Helper()
+	s, err := openStore(filepath.Join(t.TempDir(), "data.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := store
+	store = s
+	t.Cleanup(func() { store = prev })
+}
+
+func TestStaleWizardSession(t *testing.T) {
+	const chatID, userID = 100, 200
+
+	tests := []struct {
+		name    string
+		session WizardSession
+	}{
+		{"unknown flow", WizardSession{Flow: "renamed_flow", Step: "name"}},
+		{"unknown step", WizardSession{Flow: "squad_move", Step: "renamed_step"}},
+		{"unknown step in history", WizardSession{Flow: "squad_move", Step: "target", History: []string{"renamed_step"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testStore(t)
+			bot, fake := testBot(t)
+
+			s := tt.session
+			s.ChatID, s.UserID = chatID, userID
+			saveSession(&s)
+
+			msg := &tgbotapi.Message{
+				Text: "ivan",
+				Chat: &tgbotapi.Chat{ID: chatID, Type: "private"},
+				From: &tgbotapi.User{ID: userID},
+			}
+			if !handleWizardMessage(context.Background(), bot, msg) {
+				t.Fatal("message not taken by the stale session")
+			}
+			handleWizardCallback(context.Background(), bot, chatID, userID, "wz:back")
+
+			if getSession(context.Background(), chatID, userID) != nil {
+				t.Fatal("stale session kept")
+			}
+			if len(fake.texts) == 0 || !strings.Contains(fake.texts[0], "Начните его заново") {
+				t.Fatalf("user not told to start again: %q", fake.texts)
+			}
+		})
+	}
+}